@@ -0,0 +1,226 @@
+package twist
+
+import (
+	"fmt"
+	"regexp/syntax"
+	"strings"
+)
+
+// An Ambiguity is a place in a template where a string can be split into fields in more than
+// one way when parsing, see Twist.Analyze.
+type Ambiguity struct {
+	// The field whose end can't always be found
+	Field string
+	// The field after the ambiguity, if any
+	Next string
+	// The literal text between the fields that can also appear inside them
+	Separator string
+	// A description of the ambiguity
+	Reason string
+}
+
+// Return the description of the ambiguity
+func (a Ambiguity) String() string {
+	return a.Reason
+}
+
+// Analyze inspects the structure of the template and reports every place where parsing a
+// string could give more than one result. These are fields that are not separated by any
+// text, text between fields that could also appear inside both of them, and optional
+// sections that could be matched by the field before them. The analysis only considers the
+// characters each field can contain, so it may report ambiguities that never occur in
+// practice, and doesn't consider text spanning several fields, so it can miss some unusual
+// ones.
+func (t Twist) Analyze() []Ambiguity {
+	var ambiguities []Ambiguity
+	full := t.matcher.full
+	for i := 0; i+1 < len(full.fields); i++ {
+		field := t.fieldParts[full.fields[i]]
+		next := t.fieldParts[full.fields[i+1]]
+		separator := full.pretext[i+1]
+
+		switch {
+		case separator == "":
+			ambiguities = append(ambiguities, Ambiguity{
+				Field:  field.String(),
+				Next:   next.String(),
+				Reason: fmt.Sprintf("fields '%s' and '%s' are not separated by any text", field, next),
+			})
+		case field.mayContainInside(separator) && t.mayRepeat(full, i+1, separator):
+			ambiguities = append(ambiguities, Ambiguity{
+				Field:     field.String(),
+				Next:      next.String(),
+				Separator: separator,
+				Reason:    fmt.Sprintf("text '%s' after field '%s' can also appear in the field and later in the template", separator, field),
+			})
+		}
+	}
+
+	pretext := t.pretext()
+	for _, sec := range t.sections {
+		if sec.first > 0 {
+			// The field before the section could match the whole of it when it's left out
+			field := t.fieldParts[sec.first-1]
+			leading := pretext[sec.first][sec.start:]
+			if field.mayContainInside(leading) {
+				ambiguities = append(ambiguities, Ambiguity{
+					Field:     field.String(),
+					Next:      t.fieldParts[sec.first].String(),
+					Separator: leading,
+					Reason:    fmt.Sprintf("optional section after field '%s' can also be matched by the field", field),
+				})
+			}
+		} else if sec.last+1 < len(t.fieldParts) {
+			field := t.fieldParts[sec.last+1]
+			trailing := pretext[sec.last+1][:sec.end]
+			if field.mayContain(trailing) {
+				ambiguities = append(ambiguities, Ambiguity{
+					Field:     t.fieldParts[sec.last].String(),
+					Next:      field.String(),
+					Separator: trailing,
+					Reason:    fmt.Sprintf("optional section before field '%s' can also be matched by the field", field),
+				})
+			}
+		}
+	}
+	return ambiguities
+}
+
+// Check whether text could appear again in a variant after the field at index k, either in
+// a later field or a later pretext
+func (t Twist) mayRepeat(v variant, k int, text string) bool {
+	for _, fieldIdx := range v.fields[k:] {
+		if t.fieldParts[fieldIdx].mayContain(text) {
+			return true
+		}
+	}
+	for _, pretext := range v.pretext[k+1:] {
+		if strings.Contains(pretext, text) {
+			return true
+		}
+	}
+	return false
+}
+
+// The characters that can appear in the text of fields of each type
+var kindChars = map[fieldKind]string{
+	kindInt:      "0123456789+-",
+	kindUint:     "0123456789+",
+	kindFloat:    "0123456789+-._eEpPxXabcdefABCDEFinftyINFTYN",
+	kindBool:     "01tTrRuUeEfFaAlLsS",
+	kindDuration: "0123456789.+-nsuµmh",
+}
+
+// The characters in integers when using Go's integer literal syntax, see WithIntegerLiterals
+const literalChars = "_xXoObBabcdefABCDEF"
+
+// The characters that can appear in the text of fields with each printf verb, including
+// padding
+var verbChars = map[byte]string{
+	'd': "0123456789+- ",
+	'x': "0123456789abcdef+- ",
+	'X': "0123456789ABCDEF+- ",
+	'o': "01234567+- ",
+	'b': "01+- ",
+	'f': kindChars[kindFloat] + " ",
+	'F': kindChars[kindFloat] + " ",
+	'e': kindChars[kindFloat] + " ",
+	'E': kindChars[kindFloat] + " ",
+	'g': kindChars[kindFloat] + " ",
+	'G': kindChars[kindFloat] + " ",
+	't': kindChars[kindBool] + " ",
+}
+
+// Check whether the text could appear inside the text of the field. This only checks that
+// every character of the text is allowed by the field's constraints.
+func (f field) mayContain(text string) bool {
+	for _, r := range text {
+		if !f.allows(r) {
+			return false
+		}
+	}
+	return true
+}
+
+// Check whether the text could appear after the start of the text of the field, so the
+// field could end before it. This is mayContain, except that signs can only start numbers.
+func (f field) mayContainInside(text string) bool {
+	for _, r := range text {
+		if !f.allows(r) || f.leadingOnly(r) {
+			return false
+		}
+	}
+	return true
+}
+
+// Check whether the character can only be the first character of the field's text, e.g. the
+// sign of an integer, ignoring any padding
+func (f field) leadingOnly(r rune) bool {
+	// An empty placeholder lets the field end before any sign
+	if r != '+' && r != '-' || f.list || f.nullable && (f.placeholder == "" || strings.ContainsRune(f.placeholder, r)) {
+		return false
+	}
+	switch f.kind {
+	case kindInt, kindUint, kindDuration:
+		return true
+	}
+	return strings.ContainsRune("dxXob", rune(f.verb.char))
+}
+
+// Check whether the character could appear in the text of the field
+func (f field) allows(r rune) bool {
+	if f.list && strings.ContainsRune(f.sep, r) {
+		return true
+	}
+	if f.nullable && strings.ContainsRune(f.placeholder, r) {
+		return true
+	}
+	if chars, ok := kindChars[f.kind]; ok {
+		if f.literals && (f.kind == kindInt || f.kind == kindUint) {
+			chars += literalChars
+		}
+		if !strings.ContainsRune(chars, r) {
+			return false
+		}
+	}
+	if chars, ok := verbChars[f.verb.char]; ok && !strings.ContainsRune(chars, r) {
+		return false
+	}
+	if f.pattern != nil {
+		re, err := syntax.Parse(f.pattern.String(), syntax.Perl)
+		if err == nil && !patternAllows(re, r) {
+			return false
+		}
+	}
+	return true
+}
+
+// Check whether the character could appear in text matched by a regular expression
+func patternAllows(re *syntax.Regexp, r rune) bool {
+	switch re.Op {
+	case syntax.OpLiteral:
+		for _, lit := range re.Rune {
+			if lit == r || re.Flags&syntax.FoldCase != 0 && strings.EqualFold(string(lit), string(r)) {
+				return true
+			}
+		}
+		return false
+	case syntax.OpCharClass:
+		for i := 0; i+1 < len(re.Rune); i += 2 {
+			if re.Rune[i] <= r && r <= re.Rune[i+1] {
+				return true
+			}
+		}
+		return false
+	case syntax.OpAnyCharNotNL:
+		return r != '\n'
+	case syntax.OpAnyChar:
+		return true
+	}
+	for _, sub := range re.Sub {
+		if patternAllows(sub, r) {
+			return true
+		}
+	}
+	return false
+}
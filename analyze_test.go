@@ -0,0 +1,237 @@
+package twist
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestAnalyze(t *testing.T) {
+	type testCase struct {
+		name     string
+		template string
+		opts     []twistOption
+		want     []string
+	}
+
+	tests := []testCase{
+		{
+			name:     "single field",
+			template: "{{Name}}.log",
+		},
+		{
+			name:     "adjacent fields",
+			template: "{{First}}{{Last}}",
+			want:     []string{"fields 'First' and 'Last' are not separated by any text"},
+		},
+		{
+			name:     "separator in fields",
+			template: "{{Service}}-{{Env}}.yaml",
+			want:     []string{"text '-' after field 'Service' can also appear in the field and later in the template"},
+		},
+		{
+			name:     "repeated separators",
+			template: "{{A}}--{{B}}--{{C}}",
+			want: []string{
+				"text '--' after field 'A' can also appear in the field and later in the template",
+				"text '--' after field 'B' can also appear in the field and later in the template",
+			},
+		},
+		{
+			name:     "separator not in typed field",
+			template: "{{Service}}_{{Seq:int}}.log",
+		},
+		{
+			name:     "sign between typed fields",
+			template: "{{A:int}}-{{B:int}}/{{C:%03d}}+{{D:duration}}-{{E:int}}",
+		},
+		{
+			name:     "sign in a later typed field",
+			template: "{{A}}-{{B:int}}",
+			want:     []string{"text '-' after field 'A' can also appear in the field and later in the template"},
+		},
+		{
+			name:     "sign in a float exponent",
+			template: "{{A:float}}-{{B:float}}",
+			want:     []string{"text '-' after field 'A' can also appear in the field and later in the template"},
+		},
+		{
+			name:     "separator not in following fields",
+			template: "{{Service}}-{{Seq:uint}}.{{Name /[a-z]+/}}",
+		},
+		{
+			name:     "separator in a later field",
+			template: "{{A}}-{{B /[a-z]+/}}/{{C}}",
+			want:     []string{"text '-' after field 'A' can also appear in the field and later in the template"},
+		},
+		{
+			name:     "separator in later text",
+			template: "{{A}}-{{B:uint}}.{{C:uint}}-x",
+			want:     []string{"text '-' after field 'A' can also appear in the field and later in the template"},
+		},
+		{
+			name:     "separator in signed field",
+			template: "{{Service}}-{{Offset:int}}",
+			want:     []string{"text '-' after field 'Service' can also appear in the field and later in the template"},
+		},
+		{
+			name:     "separator not in pattern",
+			template: "{{Id /[0-9a-f]{8}/}}-{{Name}}",
+		},
+		{
+			name:     "separator in pattern",
+			template: "{{Id /[a-z-]+/}}-{{Name}}",
+			want:     []string{"text '-' after field 'Id' can also appear in the field and later in the template"},
+		},
+		{
+			name:     "separator not in format",
+			template: "{{Seq:%06d}}_{{Name}}",
+		},
+		{
+			name:     "separator in list",
+			template: "{{Tags...}},{{Name}}",
+			want:     []string{"text ',' after field 'Tags' can also appear in the field and later in the template"},
+		},
+		{
+			name:     "separator in placeholder",
+			template: "{{Seq:int}}_{{Name}}",
+			opts:     []twistOption{WithNilPlaceholder("n_a")},
+			want:     []string{"text '_' after field 'Seq' can also appear in the field and later in the template"},
+		},
+		{
+			name:     "integer literals",
+			template: "{{A:int}}_{{B}}",
+			opts:     []twistOption{WithIntegerLiterals()},
+			want:     []string{"text '_' after field 'A' can also appear in the field and later in the template"},
+		},
+		{
+			name:     "optional section",
+			template: "{{Name}}[-{{Suffix}}].log",
+			opts:     []twistOption{WithOptionalSections([2]string{"[", "]"})},
+			want: []string{
+				"text '-' after field 'Name' can also appear in the field and later in the template",
+				"optional section after field 'Name' can also be matched by the field",
+			},
+		},
+		{
+			name:     "optional section at start",
+			template: "[{{Prefix}}/]{{Name}}",
+			opts:     []twistOption{WithOptionalSections([2]string{"[", "]"})},
+			want: []string{
+				"text '/' after field 'Prefix' can also appear in the field and later in the template",
+				"optional section before field 'Name' can also be matched by the field",
+			},
+		},
+		{
+			name:     "unambiguous optional section",
+			template: "{{Name /[a-z]+/}}[-{{Seq:uint}}].log",
+			opts:     []twistOption{WithOptionalSections([2]string{"[", "]"})},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpl, err := New(tt.template, tt.opts...)
+			if err != nil {
+				t.Errorf("New() error = %v", err)
+				return
+			}
+			var got []string
+			for _, ambiguity := range tmpl.Analyze() {
+				got = append(got, ambiguity.String())
+			}
+			if diff := cmp.Diff(got, tt.want); diff != "" {
+				t.Errorf("Analyze() mismatch (-got +want)\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestAnalyzeFields(t *testing.T) {
+	got := MustNew("{{Service}}-{{Env}}").Analyze()
+	want := []Ambiguity{{
+		Field:     "Service",
+		Next:      "Env",
+		Separator: "-",
+		Reason:    "text '-' after field 'Service' can also appear in the field and later in the template",
+	}}
+	if diff := cmp.Diff(got, want); diff != "" {
+		t.Errorf("Analyze() mismatch (-got +want)\n%s", diff)
+	}
+}
+
+// Check templates that Analyze finds unambiguous against every string made of numbers and
+// signs, which can only start a number
+func TestAnalyzeSigns(t *testing.T) {
+	type testCase struct {
+		template      string
+		opts          []twistOption
+		wantAmbiguous bool
+	}
+
+	tests := []testCase{
+		{template: "{{A:int}}-{{B:int}}-{{C:int}}"},
+		{template: "{{A:int}}+{{B:int}}"},
+		{template: "{{A:uint}}+{{B:uint}}+{{C:uint}}"},
+		{template: "{{A:duration}}-{{B:duration}}"},
+		{template: "{{A:%d}}-{{B:%3d}}-{{C:%x}}"},
+		{template: "{{A:int}}-{{B:int}}", opts: []twistOption{WithNilPlaceholder("null")}},
+		{template: "{{A:int}}-{{B:int}}-{{C:int}}", opts: []twistOption{WithNilPlaceholder("")}, wantAmbiguous: true},
+	}
+
+	// Every string of up to 6 tokens
+	tokens := []string{"1", "-", "+", " ", "1s"}
+	inputs := []string{""}
+	for n, prev := 0, []string{""}; n < 6; n++ {
+		var next []string
+		for _, s := range prev {
+			for _, token := range tokens {
+				next = append(next, s+token)
+			}
+		}
+		inputs = append(inputs, next...)
+		prev = next
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.template, func(t *testing.T) {
+			tmpl := MustNew(tt.template, tt.opts...)
+			if ambiguous := len(tmpl.Analyze()) > 0; ambiguous != tt.wantAmbiguous {
+				t.Fatalf("Analyze() = %v, want ambiguous %v", tmpl.Analyze(), tt.wantAmbiguous)
+			}
+			if tt.wantAmbiguous {
+				return
+			}
+			for _, input := range inputs {
+				got, err := tmpl.ParseToMaps(input)
+				if err == nil && len(got) > 1 {
+					t.Errorf("ParseToMaps(%q) = %v, want at most one match", input, got)
+				}
+			}
+		})
+	}
+}
+
+func TestWithUnambiguous(t *testing.T) {
+	for _, template := range []string{"{{Service}}_{{Seq:int}}.log", "{{A:int}}-{{B:int}}"} {
+		if _, err := New(template, WithUnambiguous()); err != nil {
+			t.Errorf("New(%q) error = %v", template, err)
+		}
+	}
+
+	_, err := New("{{A}}{{B}}-{{C}}", WithUnambiguous())
+	if !errors.Is(err, ErrInvalidTemplate) {
+		t.Errorf("New() error type = '%v', want type '%v'", err, ErrInvalidTemplate)
+		return
+	}
+	for _, want := range []string{
+		"line 1, column 3: fields 'A' and 'B' are not separated by any text",
+		"line 1, column 8: text '-' after field 'B' can also appear in the field and later in the template",
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("New() error = '%v', want to contain '%v'", err, want)
+		}
+	}
+}
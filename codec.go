@@ -0,0 +1,61 @@
+package twist
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// Converts values of a single type to and from text, see WithCodec and WithNamedCodec
+type codec struct {
+	name   string
+	typ    reflect.Type
+	encode func(reflect.Value) (string, error)
+	decode func(string) (reflect.Value, error)
+}
+
+// Construct a codec from a pair of functions for type T
+func newCodec[T any](name string, encode func(T) (string, error), decode func(string) (T, error)) (*codec, error) {
+	if encode == nil || decode == nil {
+		return nil, fmt.Errorf("codec functions must not be nil: %w", ErrInvalidConfig)
+	}
+	return &codec{
+		name: name,
+		typ:  reflect.TypeFor[T](),
+		encode: func(v reflect.Value) (string, error) {
+			return encode(v.Interface().(T))
+		},
+		decode: func(s string) (reflect.Value, error) {
+			v, err := decode(s)
+			return reflect.ValueOf(&v).Elem(), err
+		},
+	}, nil
+}
+
+// Convert a value to text if it holds, or points to, the codec's type. Returns false if the
+// codec can't be used for the value.
+func (c *codec) format(v any) (string, bool, error) {
+	val := reflect.ValueOf(v)
+	for val.IsValid() && !val.Type().AssignableTo(c.typ) && val.Kind() == reflect.Ptr && !val.IsNil() {
+		val = val.Elem()
+	}
+	if !val.IsValid() || !val.Type().AssignableTo(c.typ) {
+		return "", false, nil
+	}
+	s, err := c.encode(val)
+	return s, true, err
+}
+
+// Find the codec registered for the type of a value, or the type it points to. Returns nil
+// if there is no such codec.
+func findCodec(codecs map[reflect.Type]*codec, t reflect.Type) *codec {
+	for t != nil {
+		if c, ok := codecs[t]; ok {
+			return c
+		}
+		if t.Kind() != reflect.Ptr {
+			break
+		}
+		t = t.Elem()
+	}
+	return nil
+}
@@ -0,0 +1,217 @@
+package twist
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+type semver struct {
+	Major, Minor, Patch int
+}
+
+func encodeSemver(v semver) (string, error) {
+	return fmt.Sprintf("v%d.%d.%d", v.Major, v.Minor, v.Patch), nil
+}
+
+func decodeSemver(s string) (semver, error) {
+	var v semver
+	_, err := fmt.Sscanf(s, "v%d.%d.%d", &v.Major, &v.Minor, &v.Patch)
+	return v, err
+}
+
+type shardID int
+
+func encodeShard(id shardID) (string, error) {
+	if id < 0 {
+		return "", errors.New("negative shard")
+	}
+	return fmt.Sprintf("s%03d", id), nil
+}
+
+func decodeShard(s string) (shardID, error) {
+	var id shardID
+	if len(s) != 4 {
+		return 0, errors.New("invalid shard")
+	}
+	_, err := fmt.Sscanf(s, "s%03d", &id)
+	return id, err
+}
+
+func TestCodecsRoundTrip(t *testing.T) {
+	type Release struct {
+		Name    string
+		Version semver
+		Prev    *semver
+		Shard   shardID
+		Shards  []shardID
+	}
+	data := Release{
+		Name:    "api-v2",
+		Version: semver{1, 2, 3},
+		Prev:    &semver{1, 2, 0},
+		Shard:   7,
+		Shards:  []shardID{1, 20},
+	}
+
+	tmpl, err := New(
+		"{{ Name }}_{{ Version }}_{{ Prev }}_{{ Shard|shard }}_{{ Shards...|shard }}",
+		WithCodec(encodeSemver, decodeSemver),
+		WithNamedCodec("shard", encodeShard, decodeShard),
+	)
+	if err != nil {
+		t.Errorf("New() error = %v", err)
+		return
+	}
+
+	got, err := tmpl.Execute(data)
+	if err != nil {
+		t.Errorf("Execute() error = %v", err)
+		return
+	}
+	if want := "api-v2_v1.2.3_v1.2.0_s007_s001,s020"; got != want {
+		t.Errorf("Execute() = %v, want %v", got, want)
+		return
+	}
+
+	var out Release
+	if err := tmpl.Parse(got, &out); err != nil {
+		t.Errorf("Parse() error = %v", err)
+		return
+	}
+	if diff := cmp.Diff(out, data); diff != "" {
+		t.Errorf("Parse() mismatch (-got +want)\n%s", diff)
+	}
+
+	// The text from ParseToMap can be executed again
+	fields, err := tmpl.ParseToMap(got)
+	if err != nil {
+		t.Errorf("ParseToMap() error = %v", err)
+		return
+	}
+	if again, err := tmpl.Execute(fields); err != nil || again != got {
+		t.Errorf("Execute() = %v, %v, want %v", again, err, got)
+	}
+}
+
+func TestNamedCodecDisambiguates(t *testing.T) {
+	tmpl := MustNew("{{ Name }}-{{ Shard|shard }}-{{ Suffix }}", WithNamedCodec("shard", encodeShard, decodeShard))
+	got, err := tmpl.ParseToMap("a-s001-b-s002-c")
+	if err == nil || !errors.Is(err, ErrAmbiguousTemplate) {
+		t.Errorf("ParseToMap() error = %v, want %v", err, ErrAmbiguousTemplate)
+	}
+	got, err = tmpl.ParseToMap("a-b-s001-c-d")
+	if err != nil {
+		t.Errorf("ParseToMap() error = %v", err)
+		return
+	}
+	want := map[string]string{"Name": "a-b", "Shard": "s001", "Suffix": "c-d"}
+	if diff := cmp.Diff(got, want); diff != "" {
+		t.Errorf("ParseToMap() mismatch (-got +want)\n%s", diff)
+	}
+}
+
+func TestCodecsError(t *testing.T) {
+	type testCase struct {
+		name      string
+		template  string
+		opts      []twistOption
+		data      any
+		parse     string
+		out       any
+		errorType error
+		errorMsg  string
+	}
+
+	shard := WithNamedCodec("shard", encodeShard, decodeShard)
+	tests := []testCase{
+		{
+			name:      "unknown codec",
+			template:  "{{ Shard|other }}",
+			opts:      []twistOption{shard},
+			errorType: ErrInvalidTemplate,
+			errorMsg:  "field 'Shard' has unknown codec 'other'",
+		},
+		{
+			name:      "nil functions",
+			template:  "{{ Version }}",
+			opts:      []twistOption{WithCodec[semver](nil, decodeSemver)},
+			errorType: ErrInvalidConfig,
+			errorMsg:  "codec functions must not be nil",
+		},
+		{
+			name:      "invalid name",
+			template:  "{{ Shard }}",
+			opts:      []twistOption{WithNamedCodec("a-b", encodeShard, decodeShard)},
+			errorType: ErrInvalidConfig,
+			errorMsg:  "codec name 'a-b' must be a letter followed by letters, digits, and underscores",
+		},
+		{
+			name:      "encode wrong type",
+			template:  "{{ Shard|shard }}",
+			opts:      []twistOption{shard},
+			data:      map[string]any{"Shard": 1.5},
+			errorType: ErrInvalidData,
+			errorMsg:  "field 'Shard' cannot be encoded with codec 'shard'",
+		},
+		{
+			name:      "encode error",
+			template:  "{{ Shard|shard }}",
+			opts:      []twistOption{shard},
+			data:      map[string]any{"Shard": shardID(-1)},
+			errorType: ErrInvalidData,
+			errorMsg:  "field 'Shard' cannot be encoded with codec 'shard'",
+		},
+		{
+			name:      "encoded string is invalid",
+			template:  "{{ Shard|shard }}",
+			opts:      []twistOption{shard},
+			data:      map[string]any{"Shard": "x"},
+			errorType: ErrInvalidData,
+			errorMsg:  "field 'Shard' cannot be decoded with codec 'shard'",
+		},
+		{
+			name:      "decode wrong type",
+			template:  "{{ Shard|shard }}",
+			opts:      []twistOption{shard},
+			parse:     "s001",
+			out:       &struct{ Shard string }{},
+			errorType: ErrInvalidData,
+			errorMsg:  "field 'Shard' cannot be decoded with codec 'shard'",
+		},
+		{
+			name:      "decode error",
+			template:  "{{ Version }}",
+			opts:      []twistOption{WithCodec(encodeSemver, decodeSemver)},
+			parse:     "1.2",
+			out:       &struct{ Version semver }{},
+			errorType: ErrInvalidData,
+			errorMsg:  "field 'Version' cannot be converted to supplied type",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpl, err := New(tt.template, tt.opts...)
+			if err == nil && tt.data != nil {
+				_, err = tmpl.Execute(tt.data)
+			} else if err == nil && tt.out != nil {
+				err = tmpl.Parse(tt.parse, tt.out)
+			}
+			if err == nil {
+				t.Errorf("error is nil")
+				return
+			}
+			if !errors.Is(err, tt.errorType) {
+				t.Errorf("error type = '%v', want type '%v'", err, tt.errorType)
+				return
+			}
+			if !strings.Contains(err.Error(), tt.errorMsg) {
+				t.Errorf("error = '%v', want to contain '%v'", err, tt.errorMsg)
+			}
+		})
+	}
+}
@@ -1,82 +1,369 @@
 package twist
 
 import (
+	"encoding"
+	"errors"
 	"fmt"
 	"reflect"
+	"slices"
 	"strconv"
+	"strings"
+	"time"
 )
 
+// Converts the text parsed for each field into the fields of a struct
+type decoder struct {
+	// The template fields, used for field specific conversions, e.g. separators of lists
+	fields map[string]field
+	codecs map[reflect.Type]*codec
+	// The field names in template order, which fields are decoded in
+	order []string
+	// Whether to decode every field and report all errors, see WithAllErrors
+	allErrors bool
+}
+
+// Construct a decoder for the fields of a template
+func (t Twist) decoder() decoder {
+	d := decoder{fields: t.fieldsByName, codecs: t.codecs, order: t.fieldNames, allErrors: t.allErrors}
+	if d.fields != nil {
+		return d
+	}
+	d.fields = make(map[string]field, len(t.fieldParts))
+	for _, f := range t.fieldParts {
+		d.fields[f.String()] = f
+	}
+	d.order = t.fields()
+	return d
+}
+
+// Find the codec to use for a field of the given type. Returns nil if there is none.
+func (d decoder) codec(key string, t reflect.Type) *codec {
+	if f, ok := d.fields[key]; ok && f.codec != nil {
+		if f.codec.typ.AssignableTo(t) {
+			return f.codec
+		}
+		return nil
+	}
+	if c, ok := d.codecs[t]; ok {
+		return c
+	}
+	return nil
+}
+
 func decode(input map[string]string, out any) error {
+	return decoder{}.decode(input, out)
+}
+
+func (d decoder) decode(input map[string]string, out any) error {
 	// Validate that 'out' is a pointer to a struct
 	outVal, err := validateOut(out)
 	if err != nil {
 		return err
 	}
 
-	// Write fields to the data struct and convert to the correct type
-	for key, value := range input {
-		field := outVal.FieldByName(key)
-		if !field.IsValid() {
-			return fmt.Errorf("field '%s' is missing: %w", key, ErrInvalidData)
+	// Write fields to the data struct and convert to the correct type, in template order so
+	// the errors reported don't depend on the order of the map
+	errs := fieldErrors{all: d.allErrors}
+	for _, key := range d.keys(input) {
+		if err := d.decodePath(outVal, key, key, input[key]); err != nil {
+			var fieldErr *FieldError
+			if !errors.As(err, &fieldErr) {
+				return err
+			}
+			if errs.add(fieldErr) {
+				break
+			}
 		}
+	}
+	return errs.join(d.order)
+}
 
-		switch field.Kind() {
-		case reflect.String:
-			field.SetString(value)
+// Return the keys of the input in template order, followed by any other keys sorted by name
+func (d decoder) keys(input map[string]string) []string {
+	keys := make([]string, 0, len(input))
+	for _, key := range d.order {
+		if _, ok := input[key]; ok && !slices.Contains(keys, key) {
+			keys = append(keys, key)
+		}
+	}
+	var others []string
+	for key := range input {
+		if _, ok := d.fields[key]; !ok {
+			others = append(others, key)
+		}
+	}
+	slices.Sort(others)
+	return append(keys, others...)
+}
 
-		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
-			intValue, err := strconv.Atoi(value)
-			if err != nil {
-				return fmt.Errorf("field '%s' cannot be converted to supplied type: %w", key, ErrInvalidData)
-			}
-			switch field.Kind() {
-			case reflect.Int:
-				field.SetInt(int64(intValue))
-			case reflect.Int8:
-				field.SetInt(int64(intValue))
-			case reflect.Int16:
-				field.SetInt(int64(intValue))
-			case reflect.Int32:
-				field.SetInt(int64(intValue))
-			case reflect.Int64:
-				field.SetInt(int64(intValue))
-			case reflect.Uint:
-				field.SetUint(uint64(intValue))
-			case reflect.Uint8:
-				field.SetUint(uint64(intValue))
-			case reflect.Uint16:
-				field.SetUint(uint64(intValue))
-			case reflect.Uint32:
-				field.SetUint(uint64(intValue))
-			case reflect.Uint64:
-				field.SetUint(uint64(intValue))
-			}
+// Walk a dotted path, e.g. 'Owner.Team.Name', through nested structs and maps and decode the
+// value into the field it refers to. Any nil pointers or maps on the way are allocated.
+func (d decoder) decodePath(v reflect.Value, path string, key string, value string) error {
+	if path == "" {
+		return d.decodeField(v, key, value)
+	}
 
-		case reflect.Bool:
-			boolValue, err := strconv.ParseBool(value)
-			if err != nil {
-				return fmt.Errorf("field '%s' cannot be converted to supplied type: %w", key, ErrInvalidData)
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface && !v.IsNil() {
+		if v.Kind() == reflect.Ptr && v.IsNil() {
+			if !v.CanSet() {
+				return &FieldError{Field: key, Value: value, Kind: v.Type().String(), Reason: "cannot be set", err: ErrInvalidData}
 			}
-			field.SetBool(boolValue)
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		v = v.Elem()
+	}
+
+	segment, rest, _ := strings.Cut(path, ".")
+	switch {
+	case v.Kind() == reflect.Struct:
+		field := fieldByName(v, segment)
+		if !field.IsValid() {
+			return &FieldError{Field: key, Value: value, Reason: "is missing", err: ErrInvalidData}
+		}
+		return d.decodePath(field, rest, key, value)
+
+	case v.Kind() == reflect.Map && v.Type().Key().Kind() == reflect.String:
+		// Map elements can't be set directly so decode into a copy and store that
+		if v.IsNil() {
+			v.Set(reflect.MakeMap(v.Type()))
+		}
+		mapKey := reflect.ValueOf(segment).Convert(v.Type().Key())
+		elem := reflect.New(v.Type().Elem()).Elem()
+		if existing := v.MapIndex(mapKey); existing.IsValid() {
+			elem.Set(existing)
+		}
+		if err := d.decodePath(elem, rest, key, value); err != nil {
+			return err
+		}
+		v.SetMapIndex(mapKey, elem)
+		return nil
+
+	default:
+		return &FieldError{Field: key, Value: value, Reason: "is missing", err: ErrInvalidData}
+	}
+}
 
-		case reflect.Float64, reflect.Float32:
-			bitSize := 64
-			if field.Kind() == reflect.Float32 {
-				bitSize = 32
+// Decode a value into a field, splitting it into elements if the field is a slice
+func (d decoder) decodeField(field reflect.Value, key string, value string) error {
+	if !field.CanSet() {
+		return &FieldError{Field: key, Value: value, Kind: field.Type().String(), Reason: "cannot be set", err: ErrInvalidData}
+	}
+	// Only list fields are split into slices, matching how they are executed
+	f, ok := d.fields[key]
+	if field.Kind() != reflect.Slice || ok && !f.list || isTextUnmarshaler(field.Type()) || d.codec(key, field.Type()) != nil {
+		return d.decodeValue(key, field, value)
+	}
+
+	sep := defaultSep
+	if ok {
+		sep = f.sep
+	}
+	if d.decodeNil(key, field, value) {
+		return nil
+	}
+	slice := reflect.Zero(field.Type())
+	if value != "" {
+		elements := strings.Split(value, sep)
+		slice = reflect.MakeSlice(field.Type(), len(elements), len(elements))
+		for i, element := range elements {
+			if err := d.decodeValue(key, slice.Index(i), element); err != nil {
+				return err
 			}
-			floatValue, err := strconv.ParseFloat(value, bitSize)
-			if err != nil {
-				return fmt.Errorf("field '%s' cannot be converted to supplied type: %w", key, ErrInvalidData)
+		}
+	}
+	field.Set(slice)
+	return nil
+}
+
+// Convert a value to the type of field and store it in the field
+func (d decoder) decodeValue(key string, field reflect.Value, value string) error {
+	if d.decodeNil(key, field, value) {
+		return nil
+	}
+	// Pointers are allocated and the value decoded into what they point to, unless there is a
+	// codec for the pointer type itself
+	for field.Kind() == reflect.Ptr && d.codec(key, field.Type()) == nil {
+		if field.IsNil() {
+			field.Set(reflect.New(field.Type().Elem()))
+		}
+		field = field.Elem()
+	}
+	if f, ok := d.fields[key]; ok && f.codec != nil && !f.codec.typ.AssignableTo(field.Type()) {
+		return &FieldError{
+			Field:  key,
+			Value:  value,
+			Kind:   field.Type().String(),
+			Reason: fmt.Sprintf("cannot be decoded with codec '%s'", f.codec.name),
+			err:    ErrInvalidData,
+		}
+	}
+	if codec := d.codec(key, field.Type()); codec != nil {
+		decoded, err := codec.decode(value)
+		if err != nil {
+			return convertError(key, field, value)
+		}
+		field.Set(decoded)
+		return nil
+	}
+	if f, ok := d.fields[key]; ok && f.kind == kindTime && field.Type() == timeType {
+		t, err := f.parseTime(value)
+		if err != nil {
+			return convertError(key, field, value)
+		}
+		field.Set(reflect.ValueOf(t))
+		return nil
+	}
+	if ok, err := unmarshalText(key, field, value); ok {
+		return err
+	}
+	if field.Type() == durationType {
+		duration, err := time.ParseDuration(value)
+		if err != nil {
+			return convertError(key, field, value)
+		}
+		field.SetInt(int64(duration))
+		return nil
+	}
+
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(value)
+
+	case reflect.Interface:
+		if field.NumMethod() != 0 {
+			return unsupportedError(key, field, value)
+		}
+		field.Set(reflect.ValueOf(value))
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		text, base := d.intText(key, value)
+		intValue, err := strconv.ParseInt(text, base, field.Type().Bits())
+		if err != nil {
+			return intError(key, field, value, err)
+		}
+		field.SetInt(intValue)
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		text, base := d.intText(key, value)
+		uintValue, err := strconv.ParseUint(text, base, field.Type().Bits())
+		if err != nil {
+			if isNegative(text, base) {
+				err = strconv.ErrRange
 			}
-			field.SetFloat(floatValue)
+			return intError(key, field, value, err)
+		}
+		field.SetUint(uintValue)
 
-		default:
-			return fmt.Errorf("field '%s' is not a supported type: %w", key, ErrInvalidData)
+	case reflect.Bool:
+		boolValue, err := strconv.ParseBool(value)
+		if err != nil {
+			return convertError(key, field, value)
+		}
+		field.SetBool(boolValue)
+
+	case reflect.Float64, reflect.Float32:
+		bitSize := 64
+		if field.Kind() == reflect.Float32 {
+			bitSize = 32
+		}
+		floatValue, err := strconv.ParseFloat(value, bitSize)
+		if err != nil {
+			return convertError(key, field, value)
 		}
+		field.SetFloat(floatValue)
+
+	default:
+		return unsupportedError(key, field, value)
 	}
 	return nil
 }
 
+// Set a field to nil, or it's zero value, if the value is the text used for nil values. This
+// is the field's placeholder, see WithNilPlaceholder, or otherwise empty text for types that
+// can be nil. Returns false if the field was not set.
+func (d decoder) decodeNil(key string, field reflect.Value, value string) bool {
+	f, ok := d.fields[key]
+	switch {
+	case ok && f.nullable:
+		if !f.isPlaceholder(value) {
+			return false
+		}
+	case value != "":
+		return false
+	case field.Kind() != reflect.Ptr && field.Kind() != reflect.Interface && field.Kind() != reflect.Slice:
+		return false
+	}
+	field.Set(reflect.Zero(field.Type()))
+	return true
+}
+
+// Return the text and base used to parse an integer for a field, see field.intText
+func (d decoder) intText(key string, value string) (string, int) {
+	if f, ok := d.fields[key]; ok {
+		return f.intText(value)
+	}
+	return value, 10
+}
+
+// Construct the error for an integer that could not be parsed, distinguishing integers that
+// don't fit in the field
+func intError(key string, field reflect.Value, value string, err error) error {
+	if errors.Is(err, strconv.ErrRange) {
+		return &FieldError{
+			Field:  key,
+			Value:  value,
+			Kind:   field.Type().String(),
+			Reason: fmt.Sprintf("is out of range for '%s'", field.Type()),
+			err:    ErrOutOfRange,
+		}
+	}
+	return convertError(key, field, value)
+}
+
+// Construct the error for a field whose type can't be decoded
+func unsupportedError(key string, field reflect.Value, value string) error {
+	return &FieldError{Field: key, Value: value, Kind: field.Type().String(), Reason: "is not a supported type", err: ErrInvalidData}
+}
+
+// Check if text is a negative integer, which is out of range for unsigned types
+func isNegative(text string, base int) bool {
+	_, err := strconv.ParseInt(text, base, 64)
+	return strings.HasPrefix(text, "-") && (err == nil || errors.Is(err, strconv.ErrRange))
+}
+
+// Decode a value into a field using encoding.TextUnmarshaler if the field implements it
+// with either a value or pointer receiver. Nil pointers are allocated first. Returns false if
+// the field does not implement it.
+func unmarshalText(key string, field reflect.Value, value string) (bool, error) {
+	if field.Kind() == reflect.Ptr && field.IsNil() && field.Type().Implements(textUnmarshalerType) {
+		field.Set(reflect.New(field.Type().Elem()))
+	}
+
+	var unmarshaler encoding.TextUnmarshaler
+	if field.Kind() != reflect.Ptr && field.CanAddr() && field.Addr().Type().Implements(textUnmarshalerType) {
+		unmarshaler = field.Addr().Interface().(encoding.TextUnmarshaler)
+	} else if field.Type().Implements(textUnmarshalerType) && !(field.Kind() == reflect.Ptr && field.IsNil()) {
+		unmarshaler = field.Interface().(encoding.TextUnmarshaler)
+	} else {
+		return false, nil
+	}
+
+	if err := unmarshaler.UnmarshalText([]byte(value)); err != nil {
+		return true, convertError(key, field, value)
+	}
+	return true, nil
+}
+
+// Check if a type, or a pointer to it, implements encoding.TextUnmarshaler
+func isTextUnmarshaler(t reflect.Type) bool {
+	return t.Implements(textUnmarshalerType) || reflect.PointerTo(t).Implements(textUnmarshalerType)
+}
+
+var (
+	timeType     = reflect.TypeFor[time.Time]()
+	durationType = reflect.TypeFor[time.Duration]()
+)
+
 func validateOut(out any) (reflect.Value, error) {
 	outVal := reflect.ValueOf(out)
 	if kind := outVal.Kind(); kind != reflect.Ptr || outVal.IsNil() {
@@ -93,3 +380,18 @@ func validateOut(out any) (reflect.Value, error) {
 	}
 	return outVal, nil
 }
+
+// Decode into a new value of type T, allocating it first if T is a pointer
+func decodeAs[T any](d decoder, input map[string]string) (T, error) {
+	var out T
+	target := any(&out)
+	if t := reflect.TypeFor[T](); t.Kind() == reflect.Ptr {
+		out = reflect.New(t.Elem()).Interface().(T)
+		target = out
+	}
+	if err := d.decode(input, target); err != nil {
+		var zero T
+		return zero, err
+	}
+	return out, nil
+}
@@ -2,9 +2,12 @@ package twist
 
 import (
 	"errors"
+	"net"
+	"net/netip"
 	"reflect"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/google/go-cmp/cmp"
 )
@@ -36,6 +39,12 @@ func TestDecodeSucess(t *testing.T) {
 			out:   &struct{ Field int8 }{},
 			want:  int8(123),
 		},
+		{
+			name:  "int8 min",
+			input: "-128",
+			out:   &struct{ Field int8 }{},
+			want:  int8(-128),
+		},
 		{
 			name:  "int16",
 			input: "123",
@@ -84,6 +93,18 @@ func TestDecodeSucess(t *testing.T) {
 			out:   &struct{ Field uint64 }{},
 			want:  uint64(123),
 		},
+		{
+			name:  "uint64 max",
+			input: "18446744073709551615",
+			out:   &struct{ Field uint64 }{},
+			want:  uint64(18446744073709551615),
+		},
+		{
+			name:  "int64 min",
+			input: "-9223372036854775808",
+			out:   &struct{ Field int64 }{},
+			want:  int64(-9223372036854775808),
+		},
 		{
 			name:  "float",
 			input: "123",
@@ -114,6 +135,78 @@ func TestDecodeSucess(t *testing.T) {
 			out:   &struct{ Field bool }{},
 			want:  false,
 		},
+		{
+			name:  "duration",
+			input: "1m30s",
+			out:   &struct{ Field time.Duration }{},
+			want:  90 * time.Second,
+		},
+		{
+			name:  "int pointer",
+			input: "-12",
+			out:   &struct{ Field *int }{},
+			want:  ptr(-12),
+		},
+		{
+			name:  "string pointer",
+			input: "abc",
+			out:   &struct{ Field *string }{},
+			want:  ptr("abc"),
+		},
+		{
+			name:  "pointer to pointer",
+			input: "true",
+			out:   &struct{ Field **bool }{},
+			want:  ptr(ptr(true)),
+		},
+		{
+			name:  "empty pointer",
+			input: "",
+			out:   &struct{ Field *string }{Field: ptr("old")},
+			want:  (*string)(nil),
+		},
+		{
+			name:  "interface",
+			input: "abc",
+			out:   &struct{ Field any }{},
+			want:  any("abc"),
+		},
+		{
+			name:  "empty interface",
+			input: "",
+			out:   &struct{ Field any }{Field: 1},
+			want:  any(nil),
+		},
+		{
+			name:  "empty string",
+			input: "",
+			out:   &struct{ Field string }{},
+			want:  "",
+		},
+		{
+			name:  "pointer slice",
+			input: "1,,3",
+			out:   &struct{ Field []*uint }{},
+			want:  []*uint{ptr[uint](1), nil, ptr[uint](3)},
+		},
+		{
+			name:  "string slice",
+			input: "a,b,,c",
+			out:   &struct{ Field []string }{},
+			want:  []string{"a", "b", "", "c"},
+		},
+		{
+			name:  "int slice",
+			input: "1,-2,3",
+			out:   &struct{ Field []int }{},
+			want:  []int{1, -2, 3},
+		},
+		{
+			name:  "empty slice",
+			input: "",
+			out:   &struct{ Field []float64 }{},
+			want:  []float64(nil),
+		},
 	}
 
 	for _, tt := range tests {
@@ -133,6 +226,10 @@ func TestDecodeSucess(t *testing.T) {
 	}
 }
 
+func ptr[T any](v T) *T {
+	return &v
+}
+
 func TestDecodeMultipleSuccess(t *testing.T) {
 
 	var out struct {
@@ -175,6 +272,94 @@ func TestDecodeMultipleSuccess(t *testing.T) {
 	}
 }
 
+func TestDecodeNestedSuccess(t *testing.T) {
+	type Team struct {
+		Name string
+		Size int
+	}
+	type Owner struct {
+		Team   Team
+		Backup *Team
+	}
+	type Job struct {
+		Owner  *Owner
+		Labels map[string]string
+		Teams  map[string]Team
+	}
+
+	input := map[string]string{
+		"Owner.Team.Name":   "core",
+		"Owner.Backup.Size": "3",
+		"Labels.env":        "prod",
+		"Teams.a.Name":      "alpha",
+		"Teams.a.Size":      "4",
+	}
+	want := Job{
+		Owner: &Owner{
+			Team:   Team{Name: "core"},
+			Backup: &Team{Size: 3},
+		},
+		Labels: map[string]string{"env": "prod"},
+		Teams:  map[string]Team{"a": {Name: "alpha", Size: 4}},
+	}
+
+	var out Job
+	err := decode(input, &out)
+	if err != nil {
+		t.Errorf("decode() error = %v", err)
+		return
+	}
+	if diff := cmp.Diff(out, want); diff != "" {
+		t.Errorf("deocde() mismatch (-got +want)\n%s", diff)
+		return
+	}
+}
+
+func TestDecodeTextUnmarshalerSuccess(t *testing.T) {
+	type data struct {
+		Addr     netip.Addr
+		AddrPtr  *netip.Addr
+		Time     time.Time
+		Color    hexColor
+		ColorPtr *hexColor
+		Colors   []hexColor
+		IP       net.IP
+	}
+
+	input := map[string]string{
+		"Addr":     "10.0.0.1",
+		"AddrPtr":  "::1",
+		"Time":     "2024-01-02T03:04:05Z",
+		"Color":    "#ff0100",
+		"ColorPtr": "#000010",
+		"Colors":   "#000001,#000002",
+		"IP":       "10.0.0.2",
+	}
+	addrPtr := netip.MustParseAddr("::1")
+	want := data{
+		Addr:     netip.MustParseAddr("10.0.0.1"),
+		AddrPtr:  &addrPtr,
+		Time:     time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+		Color:    hexColor{r: 255, g: 1},
+		ColorPtr: &hexColor{b: 16},
+		Colors:   []hexColor{{b: 1}, {b: 2}},
+		IP:       net.ParseIP("10.0.0.2"),
+	}
+
+	var out data
+	if err := decode(input, &out); err != nil {
+		t.Errorf("decode() error = %v", err)
+		return
+	}
+	opts := cmp.Options{
+		cmp.AllowUnexported(hexColor{}),
+		cmp.Comparer(func(a, b netip.Addr) bool { return a == b }),
+	}
+	if diff := cmp.Diff(out, want, opts); diff != "" {
+		t.Errorf("deocde() mismatch (-got +want)\n%s", diff)
+	}
+}
+
 func TestDecodeInterfaceSuccess(t *testing.T) {
 	type empty interface{}
 	type nested interface{ empty }
@@ -237,6 +422,13 @@ func TestDecodeError(t *testing.T) {
 	testString := "test"
 
 	tests := []testCase{
+		{
+			name:      "duration without unit",
+			input:     map[string]string{"Field": "5"},
+			out:       &struct{ Field time.Duration }{},
+			errorType: ErrInvalidData,
+			errorMsg:  "field 'Field' cannot be converted to supplied type",
+		},
 		{
 			name:      "invalid int",
 			input:     map[string]string{"Field": "str"},
@@ -244,6 +436,76 @@ func TestDecodeError(t *testing.T) {
 			errorType: ErrInvalidData,
 			errorMsg:  "field 'Field' cannot be converted to supplied type",
 		},
+		{
+			name:      "int overflow",
+			input:     map[string]string{"Field": "300"},
+			out:       &struct{ Field int8 }{},
+			errorType: ErrOutOfRange,
+			errorMsg:  "field 'Field' is out of range for 'int8'",
+		},
+		{
+			name:      "int underflow",
+			input:     map[string]string{"Field": "-32769"},
+			out:       &struct{ Field int16 }{},
+			errorType: ErrOutOfRange,
+			errorMsg:  "field 'Field' is out of range for 'int16'",
+		},
+		{
+			name:      "uint overflow",
+			input:     map[string]string{"Field": "18446744073709551616"},
+			out:       &struct{ Field uint64 }{},
+			errorType: ErrOutOfRange,
+			errorMsg:  "field 'Field' is out of range for 'uint64'",
+		},
+		{
+			name:      "negative uint",
+			input:     map[string]string{"Field": "-1"},
+			out:       &struct{ Field uint32 }{},
+			errorType: ErrOutOfRange,
+			errorMsg:  "field 'Field' is out of range for 'uint32'",
+		},
+		{
+			name:      "negative uint overflow",
+			input:     map[string]string{"Field": "-99999999999999999999"},
+			out:       &struct{ Field uint }{},
+			errorType: ErrOutOfRange,
+			errorMsg:  "field 'Field' is out of range for 'uint'",
+		},
+		{
+			name:      "negative text into uint",
+			input:     map[string]string{"Field": "-x"},
+			out:       &struct{ Field uint32 }{},
+			errorType: ErrInvalidData,
+			errorMsg:  "field 'Field' cannot be converted to supplied type",
+		},
+		{
+			name:      "base prefix without literals",
+			input:     map[string]string{"Field": "0x10"},
+			out:       &struct{ Field int }{},
+			errorType: ErrInvalidData,
+			errorMsg:  "field 'Field' cannot be converted to supplied type",
+		},
+		{
+			name:      "invalid pointer",
+			input:     map[string]string{"Field": "x"},
+			out:       &struct{ Field *int }{},
+			errorType: ErrInvalidData,
+			errorMsg:  "field 'Field' cannot be converted to supplied type",
+		},
+		{
+			name:      "empty int",
+			input:     map[string]string{"Field": ""},
+			out:       &struct{ Field int }{},
+			errorType: ErrInvalidData,
+			errorMsg:  "field 'Field' cannot be converted to supplied type",
+		},
+		{
+			name:      "non-empty interface",
+			input:     map[string]string{"Field": "x"},
+			out:       &struct{ Field error }{},
+			errorType: ErrInvalidData,
+			errorMsg:  "field 'Field' is not a supported type",
+		},
 		{
 			name:      "invalid float",
 			input:     map[string]string{"Field": "str"},
@@ -258,6 +520,41 @@ func TestDecodeError(t *testing.T) {
 			errorType: ErrInvalidData,
 			errorMsg:  "field 'Field' cannot be converted to supplied type",
 		},
+		{
+			name:      "invalid slice element",
+			input:     map[string]string{"Field": "1,x"},
+			out:       &struct{ Field []int }{},
+			errorType: ErrInvalidData,
+			errorMsg:  "field 'Field' cannot be converted to supplied type",
+		},
+		{
+			name:      "missing nested field",
+			input:     map[string]string{"Field.Missing": "str"},
+			out:       &struct{ Field struct{ Name string } }{},
+			errorType: ErrInvalidData,
+			errorMsg:  "field 'Field.Missing' is missing",
+		},
+		{
+			name:      "path through a value",
+			input:     map[string]string{"Field.Name": "str"},
+			out:       &struct{ Field string }{},
+			errorType: ErrInvalidData,
+			errorMsg:  "field 'Field.Name' is missing",
+		},
+		{
+			name:      "path through a map without string keys",
+			input:     map[string]string{"Field.1": "str"},
+			out:       &struct{ Field map[int]string }{},
+			errorType: ErrInvalidData,
+			errorMsg:  "field 'Field.1' is missing",
+		},
+		{
+			name:      "invalid text",
+			input:     map[string]string{"Field": "not an ip"},
+			out:       &struct{ Field netip.Addr }{},
+			errorType: ErrInvalidData,
+			errorMsg:  "field 'Field' cannot be converted to supplied type",
+		},
 		{
 			name:      "unsupported type",
 			input:     map[string]string{"Field": "str"},
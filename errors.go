@@ -0,0 +1,121 @@
+package twist
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+	"unicode/utf8"
+)
+
+// A FieldError describes a problem with a single field when creating, executing or parsing
+// a template. It wraps ErrInvalidData, or ErrInvalidTemplate when creating a template, so
+// the sentinel errors can still be matched with errors.Is.
+type FieldError struct {
+	// The name of the field, e.g. 'Owner.Team.Name'
+	Field string
+	// The text of the field when parsing, or the value of the field when executing
+	Value string
+	// The Go type the value was converted to or from, e.g. 'int', if known
+	Kind string
+	// A short description of the problem, e.g. 'is missing'
+	Reason string
+
+	err error
+}
+
+func (e *FieldError) Error() string {
+	return fmt.Sprintf("field '%s' %s: %v", e.Field, e.Reason, e.err)
+}
+
+func (e *FieldError) Unwrap() error {
+	return e.err
+}
+
+// Construct the error for text that can't be converted to the type of a struct field
+func convertError(key string, field reflect.Value, value string) *FieldError {
+	return &FieldError{
+		Field:  key,
+		Value:  value,
+		Kind:   field.Type().String(),
+		Reason: "cannot be converted to supplied type",
+		err:    ErrInvalidData,
+	}
+}
+
+// Collects the errors for the fields of a template, keeping only the first error for each
+// field. Unless all is set, callers stop at the first error.
+type fieldErrors struct {
+	all  bool
+	errs []*FieldError
+}
+
+// Add the error for a field, returning true if the caller should stop
+func (e *fieldErrors) add(err *FieldError) bool {
+	if !slices.ContainsFunc(e.errs, func(other *FieldError) bool { return other.Field == err.Field }) {
+		e.errs = append(e.errs, err)
+	}
+	return !e.all
+}
+
+// Join the errors sorted by the position of their field in order, with any fields that are
+// not in order last. Returns nil if there are no errors.
+func (e *fieldErrors) join(order []string) error {
+	position := func(err *FieldError) int {
+		if i := slices.Index(order, err.Field); i != -1 {
+			return i
+		}
+		return len(order)
+	}
+	slices.SortStableFunc(e.errs, func(a, b *FieldError) int {
+		return cmp.Compare(position(a), position(b))
+	})
+	errs := make([]error, len(e.errs))
+	for i, err := range e.errs {
+		errs[i] = err
+	}
+	return errors.Join(errs...)
+}
+
+// Return the name of a value's type, or empty if it's nil
+func typeName(v any) string {
+	if v == nil {
+		return ""
+	}
+	return reflect.TypeOf(v).String()
+}
+
+// A TemplateError describes a problem with the text of a template when creating it. It
+// wraps ErrInvalidTemplate. New reports every problem it finds, joined with errors.Join, so
+// errors.As finds the first of them.
+type TemplateError struct {
+	// The byte offset in the template of the problem
+	Offset int
+	// The line of the problem, starting from 1
+	Line int
+	// The column of the problem in characters, starting from 1
+	Column int
+	// A short description of the problem
+	Reason string
+}
+
+// Construct the error for a problem at the given offset in a template with a formatted reason
+func newTemplateError(template string, offset int, format string, args ...any) *TemplateError {
+	lineStart := strings.LastIndexByte(template[:offset], '\n') + 1
+	return &TemplateError{
+		Offset: offset,
+		Line:   strings.Count(template[:offset], "\n") + 1,
+		Column: utf8.RuneCountInString(template[lineStart:offset]) + 1,
+		Reason: fmt.Sprintf(format, args...),
+	}
+}
+
+func (e *TemplateError) Error() string {
+	return fmt.Sprintf("line %d, column %d: %s: %v", e.Line, e.Column, e.Reason, ErrInvalidTemplate)
+}
+
+func (e *TemplateError) Unwrap() error {
+	return ErrInvalidTemplate
+}
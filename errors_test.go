@@ -0,0 +1,209 @@
+package twist
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+)
+
+func TestTemplateError(t *testing.T) {
+	type testCase struct {
+		name     string
+		template string
+		opts     []twistOption
+		want     TemplateError
+	}
+
+	tests := []testCase{
+		{
+			name:     "unmatched start",
+			template: "a-{{Name",
+			want:     TemplateError{Offset: 2, Line: 1, Column: 3, Reason: "unmatched delimiters"},
+		},
+		{
+			name:     "unmatched end",
+			template: "a-Name}}",
+			want:     TemplateError{Offset: 6, Line: 1, Column: 7, Reason: "unmatched delimiters"},
+		},
+		{
+			name:     "nested",
+			template: "{{A{{B}}",
+			want:     TemplateError{Offset: 3, Line: 1, Column: 4, Reason: "nested delimiters"},
+		},
+		{
+			name:     "invalid name",
+			template: "x-{{ 1A }}",
+			want:     TemplateError{Offset: 5, Line: 1, Column: 6, Reason: "field must start with an uppercase letter"},
+		},
+		{
+			name:     "unknown type",
+			template: "{{A}}-{{ B:number }}",
+			want:     TemplateError{Offset: 11, Line: 1, Column: 12, Reason: "field 'B' has unknown type 'number'"},
+		},
+		{
+			name:     "unmatched section",
+			template: "{{A}}[-{{B}}",
+			opts:     []twistOption{WithOptionalSections([2]string{"[", "]"})},
+			want:     TemplateError{Offset: 5, Line: 1, Column: 6, Reason: "unmatched optional section delimiters"},
+		},
+		{
+			name:     "later line",
+			template: "name: {{ Name }}\nid: {{ Id\n",
+			want:     TemplateError{Offset: 21, Line: 2, Column: 5, Reason: "unmatched delimiters"},
+		},
+		{
+			name:     "column in characters",
+			template: "héllo\n  ¿{{ id }}?",
+			want:     TemplateError{Offset: 14, Line: 2, Column: 7, Reason: "field must start with an uppercase letter"},
+		},
+		{
+			name:     "ambiguous",
+			template: "x{{A}}{{B}}",
+			opts:     []twistOption{WithUnambiguous()},
+			want:     TemplateError{Offset: 3, Line: 1, Column: 4, Reason: "fields 'A' and 'B' are not separated by any text"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := New(tt.template, tt.opts...)
+			var templateErr *TemplateError
+			if !errors.As(err, &templateErr) {
+				t.Errorf("New() error = %v, want a *TemplateError", err)
+				return
+			}
+			if !errors.Is(err, ErrInvalidTemplate) {
+				t.Errorf("New() error type = '%v', want type '%v'", err, ErrInvalidTemplate)
+			}
+			if diff := cmp.Diff(*templateErr, tt.want); diff != "" {
+				t.Errorf("New() mismatch (-got +want)\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestTemplateErrors(t *testing.T) {
+	template := "{{ Name }}-{{ 1d }}\n{{ Size:number }}}} {{ Tail"
+	_, err := New(template)
+
+	var got []TemplateError
+	for _, e := range err.(interface{ Unwrap() []error }).Unwrap() {
+		var templateErr *TemplateError
+		if !errors.As(e, &templateErr) {
+			t.Fatalf("New() error = %v, want only *TemplateError", e)
+		}
+		got = append(got, *templateErr)
+	}
+	want := []TemplateError{
+		{Offset: 14, Line: 1, Column: 15, Reason: "field must start with an uppercase letter"},
+		{Offset: 28, Line: 2, Column: 9, Reason: "field 'Size' has unknown type 'number'"},
+		{Offset: 37, Line: 2, Column: 18, Reason: "unmatched delimiters"},
+		{Offset: 40, Line: 2, Column: 21, Reason: "unmatched delimiters"},
+	}
+	if diff := cmp.Diff(got, want); diff != "" {
+		t.Errorf("New() mismatch (-got +want)\n%s", diff)
+	}
+	if !errors.Is(err, ErrInvalidTemplate) {
+		t.Errorf("New() error type = '%v', want type '%v'", err, ErrInvalidTemplate)
+	}
+}
+
+func TestFieldError(t *testing.T) {
+	type data struct {
+		Name string
+		Age  int8
+		Tags []string
+	}
+
+	type testCase struct {
+		name    string
+		run     func() error
+		want    FieldError
+		wantErr error
+	}
+
+	tests := []testCase{
+		{
+			name: "execute missing",
+			run: func() error {
+				_, err := MustNew("{{Name}}-{{Id}}").Execute(data{Name: "a"})
+				return err
+			},
+			want:    FieldError{Field: "Id", Reason: "is missing"},
+			wantErr: ErrInvalidData,
+		},
+		{
+			name: "execute invalid",
+			run: func() error {
+				_, err := MustNew("{{Name /[a-z]+/}}").Execute(data{Name: "A1"})
+				return err
+			},
+			want:    FieldError{Field: "Name", Value: "A1", Kind: "string", Reason: "does not match the field's pattern"},
+			wantErr: ErrInvalidData,
+		},
+		{
+			name: "execute separator",
+			run: func() error {
+				_, err := MustNew("{{Tags...}}").Execute(data{Tags: []string{"a,b"}})
+				return err
+			},
+			want:    FieldError{Field: "Tags", Value: "[a,b]", Kind: "[]string", Reason: "has an element containing the separator ','"},
+			wantErr: ErrInvalidData,
+		},
+		{
+			name: "parse conversion",
+			run: func() error {
+				var out data
+				return MustNew("{{Name}}-{{Age}}").Parse("a-x", &out)
+			},
+			want:    FieldError{Field: "Age", Value: "x", Kind: "int8", Reason: "cannot be converted to supplied type"},
+			wantErr: ErrInvalidData,
+		},
+		{
+			name: "parse out of range",
+			run: func() error {
+				var out data
+				return MustNew("{{Name}}-{{Age}}").Parse("a-300", &out)
+			},
+			want:    FieldError{Field: "Age", Value: "300", Kind: "int8", Reason: "is out of range for 'int8'"},
+			wantErr: ErrOutOfRange,
+		},
+		{
+			name: "decode missing",
+			run: func() error {
+				var out data
+				return decode(map[string]string{"Owner.Name": "a"}, &out)
+			},
+			want:    FieldError{Field: "Owner.Name", Value: "a", Reason: "is missing"},
+			wantErr: ErrInvalidData,
+		},
+		{
+			name: "typed missing",
+			run: func() error {
+				_, err := NewTyped[data]("{{Name}}-{{Id}}")
+				return err
+			},
+			want:    FieldError{Field: "Id", Reason: "is missing from 'twist.data'"},
+			wantErr: ErrInvalidTemplate,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.run()
+			var fieldErr *FieldError
+			if !errors.As(err, &fieldErr) {
+				t.Errorf("error = %v, want a *FieldError", err)
+				return
+			}
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("error type = '%v', want type '%v'", err, tt.wantErr)
+			}
+			if diff := cmp.Diff(*fieldErr, tt.want, cmpopts.IgnoreUnexported(FieldError{})); diff != "" {
+				t.Errorf("error mismatch (-got +want)\n%s", diff)
+			}
+		})
+	}
+}
@@ -1,63 +1,164 @@
 package twist
 
 import (
-	"fmt"
+	"errors"
 	"strings"
 	"unicode"
 )
 
-func extractFields(s string, delimiters [2]string) ([2][]strPart, error) {
-	var fields []strPart = []strPart{}
+// Split a template into its fields, the literal text (pretext) before each field and after
+// the final field, and any optional sections. A pair of delimiters, e.g. '{{{{' or '}}}}', is
+// an escape for a single literal delimiter. Optional sections are only recognised if their
+// delimiters are not empty. Parsing carries on after a problem so that every problem in the
+// template is reported together.
+func extractFields(s string, config twistConfig) ([]field, []strPart, []section, error) {
+	var fields []field = []field{}
 	var pretext []strPart = []strPart{}
-	delimitStart := delimiters[0]
-	delimitEnd := delimiters[1]
-	nilResult := [2][]strPart{{}, {}}
-	currentString := s
-
-	offset := 0
-	for {
-		start := strings.Index(currentString, delimitStart)
-		end := strings.Index(currentString, delimitEnd)
-		nextStart := -1
-		if end != -1 {
-			offset := start + len(delimitStart)
-			if index := strings.Index(currentString[offset:], delimitStart); index == -1 {
-				nextStart = index
-			} else {
-				nextStart = index + offset
-			}
-		}
-		if start == -1 && end == -1 {
-			break
-		} else if start == -1 || end < start {
-			return nilResult, fmt.Errorf("unmatched delimiters: %w", ErrInvalidTemplate)
-		} else if nextStart != -1 && nextStart < end {
-			return nilResult, fmt.Errorf("nested delimiters: %w", ErrInvalidTemplate)
-		}
+	var sections []section
+	delimitStart := config.Delimiters[0]
+	delimitEnd := config.Delimiters[1]
+	sectionStart := config.SectionDelimiters[0]
+	sectionEnd := config.SectionDelimiters[1]
+	hasSections := sectionStart != "" && sectionEnd != ""
 
-		field := mustNewStrPart(s, start+len(delimitStart)+offset, end+offset).TrimSpace()
-		if valid, reason := isValidField(field.String()); !valid {
-			return nilResult, fmt.Errorf("%s: %w", reason, ErrInvalidTemplate)
+	// The literal text since the last field, with any escapes replaced
+	var literal strings.Builder
+	literalStart := 0
+	escaped := false
+	endLiteral := func(end int) strPart {
+		if !escaped {
+			return mustNewStrPart(s, literalStart, end)
 		}
-		fields = append(fields, field)
-		pretext = append(pretext, mustNewStrPart(s, offset, offset+start))
+		literal.WriteString(s[literalStart:end])
+		unescaped := literal.String()
+		literal.Reset()
+		escaped = false
+		return mustNewStrPart(unescaped, 0, len(unescaped))
+	}
+	// Replace the text s[i:i+n] with replacement in the literal text
+	replace := func(i, n int, replacement string) int {
+		literal.WriteString(s[literalStart:i] + replacement)
+		escaped = true
+		literalStart = i + n
+		return literalStart
+	}
 
-		offset += end + len(delimitEnd)
-		currentString = currentString[end+len(delimitEnd):]
+	var errs []error
+	fail := func(offset int, format string, args ...any) {
+		errs = append(errs, newTemplateError(s, offset, format, args...))
 	}
-	pretext = append(pretext, mustNewStrPart(s, offset, len(s)))
-	return [2][]strPart{fields, pretext}, nil
+
+	var open *section
+	openAt := 0 // offset of the start of the open section in s
+	for i := 0; i < len(s); {
+		switch {
+		case strings.HasPrefix(s[i:], delimitStart+delimitStart):
+			i = replace(i, 2*len(delimitStart), delimitStart)
+
+		case strings.HasPrefix(s[i:], delimitEnd+delimitEnd):
+			i = replace(i, 2*len(delimitEnd), delimitEnd)
+
+		case hasSections && strings.HasPrefix(s[i:], sectionStart+sectionStart):
+			i = replace(i, 2*len(sectionStart), sectionStart)
+
+		case hasSections && strings.HasPrefix(s[i:], sectionEnd+sectionEnd):
+			i = replace(i, 2*len(sectionEnd), sectionEnd)
+
+		case strings.HasPrefix(s[i:], delimitStart):
+			bodyStart := i + len(delimitStart)
+			end := strings.Index(s[bodyStart:], delimitEnd)
+			if end == -1 {
+				fail(i, "unmatched delimiters")
+				i = bodyStart
+				continue
+			}
+			if nested := strings.Index(s[bodyStart:bodyStart+end], delimitStart); nested != -1 {
+				// Carry on from the inner delimiter as it may start a valid field
+				fail(bodyStart+nested, "nested delimiters")
+				i = bodyStart + nested
+				continue
+			}
+
+			field, err := newField(mustNewStrPart(s, bodyStart, bodyStart+end), config)
+			if err != nil {
+				errs = append(errs, err)
+			}
+			fields = append(fields, field)
+			pretext = append(pretext, endLiteral(i))
+
+			i = bodyStart + end + len(delimitEnd)
+			literalStart = i
+
+		case strings.HasPrefix(s[i:], delimitEnd):
+			fail(i, "unmatched delimiters")
+			i += len(delimitEnd)
+
+		case hasSections && strings.HasPrefix(s[i:], sectionStart):
+			if open != nil {
+				fail(i, "nested optional sections")
+				i += len(sectionStart)
+				continue
+			}
+			open = &section{first: len(fields), start: literal.Len() + i - literalStart}
+			openAt = i
+			i = replace(i, len(sectionStart), "")
+
+		case hasSections && strings.HasPrefix(s[i:], sectionEnd):
+			if open == nil {
+				fail(i, "unmatched optional section delimiters")
+				i += len(sectionEnd)
+				continue
+			}
+			if open.first == len(fields) {
+				fail(openAt, "optional section has no fields")
+			}
+			if len(sections) == maxSections {
+				fail(openAt, "more than %d optional sections", maxSections)
+			}
+			open.last = len(fields) - 1
+			open.end = literal.Len() + i - literalStart
+			sections = append(sections, *open)
+			open = nil
+			i = replace(i, len(sectionEnd), "")
+
+		default:
+			i++
+		}
+	}
+	if open != nil {
+		fail(openAt, "unmatched optional section delimiters")
+	}
+	if len(errs) > 0 {
+		return nil, nil, nil, errors.Join(errs...)
+	}
+	pretext = append(pretext, endLiteral(len(s)))
+	return fields, pretext, sections, nil
 }
 
-func isValidField(field string) (bool, string) {
+func isValidField(field string, allowLowercase bool) (bool, string) {
 	if len(field) == 0 {
 		return false, "field must not be empty"
 	}
-	r := rune(field[0])
-	if !(unicode.IsUpper(r)) {
+	for _, segment := range strings.Split(field, ".") {
+		if valid, reason := isValidSegment(segment, allowLowercase); !valid {
+			return false, reason
+		}
+	}
+	return true, ""
+}
+
+// Check a single part of a dotted field name, e.g. 'Team' in 'Owner.Team.Name'
+func isValidSegment(segment string, allowLowercase bool) (bool, string) {
+	if len(segment) == 0 {
+		return false, "field must not contain empty path segments"
+	}
+	r := rune(segment[0])
+	if allowLowercase && !unicode.IsLetter(r) {
+		return false, "field must start with a letter"
+	} else if !allowLowercase && !unicode.IsUpper(r) {
 		return false, "field must start with an uppercase letter"
 	}
-	for _, r := range field {
+	for _, r := range segment {
 		if !(unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_') {
 			return false, "field must contain only letters, digits, and underscores"
 		}
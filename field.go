@@ -0,0 +1,426 @@
+package twist
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// The type of value a field holds. This limits the text a field can match when parsing.
+type fieldKind int
+
+const (
+	kindAny fieldKind = iota
+	kindString
+	kindInt
+	kindUint
+	kindFloat
+	kindBool
+	kindTime
+	kindDuration
+)
+
+var fieldKinds = map[string]fieldKind{
+	"string":   kindString,
+	"int":      kindInt,
+	"uint":     kindUint,
+	"float":    kindFloat,
+	"bool":     kindBool,
+	"time":     kindTime,
+	"duration": kindDuration,
+}
+
+// A field in a template along with any constraints on the text it can match
+type field struct {
+	name     strPart
+	kind     fieldKind
+	verb     printfVerb
+	pattern  *regexp.Regexp
+	list     bool
+	sep      string
+	layout   string
+	location *time.Location
+	codec    *codec
+	literals bool
+	// The text used for nil values. Nullable fields accept it whatever their constraints.
+	placeholder string
+	nullable    bool
+}
+
+// The separator used between the elements of a list field by default
+const defaultSep = ","
+
+// The layout used for time fields by default
+const defaultLayout = time.RFC3339
+
+// Construct a field from the text found between a pair of delimiters, e.g. ' Age:int ',
+// ' Seq:%06d ', ' When:time "2006-01-02" ', ' Id /[0-9a-f]{8}/ ', ' Tags... sep=";" ' or
+// ' Color|hexcolor '
+func newField(part strPart, config twistConfig) (field, error) {
+	part = part.TrimSpace()
+	s := part.String()
+	end := strings.IndexFunc(s, isNameEnd)
+	if end == -1 {
+		end = len(s)
+	}
+
+	f := field{name: part.Slice(0, end), literals: config.IntegerLiterals}
+	if config.NilPlaceholder != nil {
+		f.placeholder = *config.NilPlaceholder
+		f.nullable = true
+	}
+	if strings.HasSuffix(f.name.String(), "...") {
+		f.name = f.name.Slice(0, f.name.Len()-3)
+		f.list = true
+		f.sep = defaultSep
+	}
+	if valid, reason := isValidField(f.name.String(), config.LowercaseFields); !valid {
+		return field{}, newTemplateError(part.original, part.start, "%s", reason)
+	}
+
+	rest := part.Slice(end, part.Len()).TrimSpace()
+	if strings.HasPrefix(rest.String(), "|") {
+		rest = rest.Slice(1, rest.Len()).TrimSpace()
+		end := strings.IndexFunc(rest.String(), isNameEnd)
+		if end == -1 {
+			end = rest.Len()
+		}
+		name := rest.Slice(0, end).String()
+		codec, ok := config.NamedCodecs[name]
+		if !ok {
+			return field{}, newTemplateError(part.original, rest.start, "field '%s' has unknown codec '%s'", f.name, name)
+		}
+		f.codec = codec
+		rest = rest.Slice(end, rest.Len()).TrimSpace()
+	}
+
+	if strings.HasPrefix(rest.String(), ":") {
+		rest = rest.Slice(1, rest.Len()).TrimSpace()
+		end := strings.IndexFunc(rest.String(), func(r rune) bool { return r == '"' || unicode.IsSpace(r) })
+		if end == -1 {
+			end = rest.Len()
+		}
+		annotation := rest.Slice(0, end).String()
+		if strings.HasPrefix(annotation, "%") {
+			verb, ok := newPrintfVerb(annotation)
+			if !ok {
+				return field{}, newTemplateError(part.original, rest.start, "field '%s' has unsupported format '%s'", f.name, annotation)
+			}
+			f.verb = verb
+		} else {
+			kind, ok := fieldKinds[annotation]
+			if !ok {
+				return field{}, newTemplateError(part.original, rest.start, "field '%s' has unknown type '%s'", f.name, annotation)
+			}
+			f.kind = kind
+		}
+		rest = rest.Slice(end, rest.Len()).TrimSpace()
+
+		// Time fields can be followed by a quoted layout
+		if f.kind == kindTime {
+			f.layout = defaultLayout
+			f.location = config.Location
+			if strings.HasPrefix(rest.String(), `"`) {
+				quoted, err := strconv.QuotedPrefix(rest.String())
+				if err != nil {
+					return field{}, newTemplateError(part.original, rest.start, "field '%s' has an invalid layout", f.name)
+				}
+				f.layout, _ = strconv.Unquote(quoted)
+				rest = rest.Slice(len(quoted), rest.Len()).TrimSpace()
+			}
+		}
+	}
+
+	for rest.Len() > 0 {
+		var end int
+		switch option := rest.String(); {
+		case strings.HasPrefix(option, "/"):
+			end = patternEnd(option)
+			if end == -1 {
+				return field{}, newTemplateError(part.original, rest.start, "field '%s' has an unterminated pattern", f.name)
+			}
+			expr := option[1:end]
+			pattern, err := regexp.Compile(`^(?:` + expr + `)$`)
+			if err != nil {
+				return field{}, newTemplateError(part.original, rest.start, "field '%s' has an invalid pattern '%s'", f.name, expr)
+			}
+			f.pattern = pattern
+			end++
+
+		case strings.HasPrefix(option, "sep="):
+			quoted, err := strconv.QuotedPrefix(option[4:])
+			if err != nil {
+				return field{}, newTemplateError(part.original, rest.start, "field '%s' has an invalid separator", f.name)
+			}
+			sep, _ := strconv.Unquote(quoted)
+			if !f.list {
+				return field{}, newTemplateError(part.original, rest.start, "field '%s' has a separator but is not a list", f.name)
+			} else if sep == "" {
+				return field{}, newTemplateError(part.original, rest.start, "field '%s' has an empty separator", f.name)
+			}
+			f.sep = sep
+			end = 4 + len(quoted)
+
+		default:
+			return field{}, newTemplateError(part.original, rest.start, "field '%s' has unexpected text '%s'", f.name, rest)
+		}
+		rest = rest.Slice(end, rest.Len()).TrimSpace()
+	}
+	return f, nil
+}
+
+// Check if a rune ends a field or codec name
+func isNameEnd(r rune) bool {
+	return r == ':' || r == '|' || unicode.IsSpace(r)
+}
+
+// Find the index of the '/' that closes a pattern starting at s[0], ignoring any '/'
+// escaped with a backslash. Returns -1 if the pattern is not closed.
+func patternEnd(s string) int {
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '/':
+			return i
+		}
+	}
+	return -1
+}
+
+// Return the name of the field
+func (f field) String() string {
+	return f.name.String()
+}
+
+// Convert a value into the text used for the field when executing a template, using any
+// codec for the value's type. A list field accepts a slice, or a string holding the already
+// joined elements.
+func (f field) format(v any, codecs map[reflect.Type]*codec) (string, error) {
+	if isNil(v) {
+		return f.placeholder, nil
+	}
+	if !f.list {
+		return f.formatValue(v, codecs)
+	}
+
+	val := reflect.ValueOf(v)
+	for val.Kind() == reflect.Ptr && !val.IsNil() {
+		val = val.Elem()
+	}
+	if val.Kind() == reflect.String {
+		return val.String(), nil
+	}
+	if val.Kind() != reflect.Slice && val.Kind() != reflect.Array {
+		return "", errors.New("is not a slice")
+	}
+	elements := make([]string, val.Len())
+	for i := range elements {
+		element, err := f.formatValue(val.Index(i).Interface(), codecs)
+		if err != nil {
+			return "", err
+		}
+		if strings.Contains(element, f.sep) {
+			return "", fmt.Errorf("has an element containing the separator '%s'", f.sep)
+		}
+		elements[i] = element
+	}
+	return strings.Join(elements, f.sep), nil
+}
+
+// Convert a single value, or element of a list, into text
+func (f field) formatValue(v any, codecs map[reflect.Type]*codec) (string, error) {
+	if isNil(v) {
+		return f.placeholder, nil
+	}
+	if f.codec != nil {
+		s, ok, err := f.codec.format(v)
+		if !ok {
+			// Text that has already been encoded, e.g. from ParseToMap, is used as is
+			if s, isString := v.(string); isString {
+				return s, nil
+			}
+			return "", fmt.Errorf("cannot be encoded with codec '%s'", f.codec.name)
+		} else if err != nil {
+			return "", fmt.Errorf("cannot be encoded with codec '%s'", f.codec.name)
+		}
+		return s, nil
+	}
+	if codec := findCodec(codecs, reflect.TypeOf(v)); codec != nil {
+		if s, ok, err := codec.format(v); ok {
+			if err != nil {
+				return "", errors.New("is not stringable")
+			}
+			return s, nil
+		}
+	}
+	if f.verb.spec != "" {
+		s, err := f.verb.format(v)
+		if err != nil {
+			return "", fmt.Errorf("cannot be formatted with '%s'", f.verb.spec)
+		}
+		return s, nil
+	}
+	if t, ok := timeValue(v); ok && f.kind == kindTime {
+		if f.location != nil {
+			t = t.In(f.location)
+		}
+		return t.Format(f.layout), nil
+	}
+	s, err := toString(v)
+	if err != nil {
+		return "", errors.New("is not stringable")
+	}
+	return s, nil
+}
+
+// Split the text of a list field into it's elements
+func (f field) split(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, f.sep)
+}
+
+// Convert text matched by the field into a form that can be decoded
+func (f field) normalise(s string) string {
+	if f.verb.spec == "" || f.isPlaceholder(s) {
+		return s
+	}
+	if !f.list {
+		return f.verb.normalise(s)
+	}
+	elements := f.split(s)
+	for i, element := range elements {
+		elements[i] = f.verb.normalise(element)
+	}
+	return strings.Join(elements, f.sep)
+}
+
+// Check whether the field could have produced the given text
+func (f field) accepts(s string) bool {
+	valid, _ := f.validate(s)
+	return valid
+}
+
+// Check if the field accepts any text
+func (f field) unconstrained() bool {
+	return f.kind == kindAny && f.verb.spec == "" && f.pattern == nil && f.codec == nil
+}
+
+// Check the given text satisfies the field's constraints, returning the reason if not
+func (f field) validate(s string) (bool, string) {
+	if f.isPlaceholder(s) {
+		return true, ""
+	}
+	if !f.list {
+		return f.validateValue(s)
+	}
+	for _, element := range f.split(s) {
+		if valid, reason := f.validateValue(element); !valid {
+			return false, "has an element that " + reason
+		}
+	}
+	return true, ""
+}
+
+// Check a single value, or element of a list, satisfies the field's constraints
+func (f field) validateValue(s string) (bool, string) {
+	if f.isPlaceholder(s) {
+		return true, ""
+	}
+	if f.verb.spec != "" && !f.verb.accepts(s) {
+		return false, "does not match the format '" + f.verb.spec + "'"
+	}
+
+	var err error
+	switch f.kind {
+	case kindInt:
+		text, base := f.intText(s)
+		_, err = strconv.ParseInt(text, base, 64)
+	case kindUint:
+		text, base := f.intText(s)
+		_, err = strconv.ParseUint(text, base, 64)
+	case kindFloat:
+		_, err = strconv.ParseFloat(s, 64)
+	case kindBool:
+		_, err = strconv.ParseBool(s)
+	case kindTime:
+		_, err = f.parseTime(s)
+	case kindDuration:
+		_, err = time.ParseDuration(s)
+	}
+	if err != nil {
+		return false, "is not a valid " + f.kindName()
+	}
+	if f.pattern != nil && !f.pattern.MatchString(s) {
+		return false, "does not match the field's pattern"
+	}
+	if f.codec != nil {
+		if _, err := f.codec.decode(s); err != nil {
+			return false, "cannot be decoded with codec '" + f.codec.name + "'"
+		}
+	}
+	return true, ""
+}
+
+// Check if text is the field's placeholder for nil values
+func (f field) isPlaceholder(s string) bool {
+	return f.nullable && s == f.placeholder
+}
+
+// Return the text of an integer and the base to parse it with, see WithIntegerLiterals. The
+// base is only taken from an explicit 0x, 0o or 0b prefix, where 0 means it's given by the
+// prefix, so that zero padded integers, e.g. 0042, are still base 10. '_' separators between
+// the digits of base 10 integers are removed.
+func (f field) intText(s string) (string, int) {
+	if !f.literals {
+		return s, 10
+	}
+	digits := strings.TrimLeft(s, "+-")
+	if len(digits) > 1 && digits[0] == '0' && strings.ContainsRune("xXoObB", rune(digits[1])) {
+		return s, 0
+	}
+	if strings.HasPrefix(digits, "_") || strings.HasSuffix(digits, "_") || strings.Contains(digits, "__") {
+		// Leave the separators in so the integer fails to parse
+		return s, 10
+	}
+	return strings.ReplaceAll(s, "_", ""), 10
+}
+
+// Parse the text of a time field using the field's layout and location
+func (f field) parseTime(s string) (time.Time, error) {
+	location := f.location
+	if location == nil {
+		location = time.UTC
+	}
+	return time.ParseInLocation(f.layout, s, location)
+}
+
+// Get the time.Time held by a value or a pointer to a value
+func timeValue(v any) (time.Time, bool) {
+	switch t := v.(type) {
+	case time.Time:
+		return t, true
+	case *time.Time:
+		if t != nil {
+			return *t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// Return the name used for the field's type in templates
+func (f field) kindName() string {
+	for name, kind := range fieldKinds {
+		if kind == f.kind {
+			return name
+		}
+	}
+	return "value"
+}
@@ -0,0 +1,127 @@
+package twist
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// The templates used when fuzzing the parse functions
+var fuzzTemplates = []string{
+	"{{ Name }}",
+	"log-{{ Name }}-{{ Id:int }}.log",
+	"{{ A }}{{ B }}",
+	"{{ Env }}/{{ Service }}/{{ Env }}.yaml",
+	"abcdef{{ Name }}ghijkl",
+	"{{ Name }}[-{{ Suffix }}][.{{ Ext /[a-z]+/ }}]",
+	"{{ Tags... sep=\";\" }}:{{ Seq:%06d }}",
+	"{{ When:time \"2006-01-02\" }} {{ Wait:duration }} {{ Ok:bool }} {{ Ratio:float }}",
+	"{{ Owner.Team.Name }}@{{ Owner.Age:uint }}",
+}
+
+type fuzzData struct {
+	Name    string
+	Id      int
+	A, B    string
+	Env     string
+	Service string
+	Suffix  *string
+	Ext     string
+	Tags    []string
+	Seq     int
+	When    time.Time
+	Wait    time.Duration
+	Ok      bool
+	Ratio   float64
+	Owner   struct {
+		Team struct{ Name string }
+		Age  uint8
+	}
+}
+
+func FuzzNew(f *testing.F) {
+	for _, template := range fuzzTemplates {
+		f.Add(template, "a")
+	}
+	f.Add("{{ A {{ B }}", "")
+	f.Add("[{{ A }}[{{ B }}]", "")
+	f.Add("}}{{ a }}\n{{ 1:x sep=\"\" }}", "")
+	f.Add("{{ A /[/ }}{{ B:time \"", "")
+
+	f.Fuzz(func(t *testing.T, template string, value string) {
+		// The number of ways adjacent fields can split a string grows quickly with its length,
+		// so keep the executed string short for the fuzzer to make progress
+		if len(template) > 64 || len(value) > 16 {
+			return
+		}
+		tmpl, err := New(template, WithOptionalSections([2]string{"[", "]"}))
+		if err != nil {
+			var templateErr *TemplateError
+			if !errors.Is(err, ErrTwist) || (errors.Is(err, ErrInvalidTemplate) && !errors.As(err, &templateErr)) {
+				t.Fatalf("New(%q) error = %v, want a twist error", template, err)
+			}
+			return
+		}
+		tmpl.Analyze()
+
+		data := make(map[string]string)
+		for _, field := range tmpl.fields() {
+			data[field] = value
+		}
+		s, err := tmpl.Execute(data, WithUnique())
+		if err != nil {
+			if !errors.Is(err, ErrTwist) {
+				t.Fatalf("Execute(%q) with '%s' error = %v, want a twist error", value, template, err)
+			}
+			return
+		}
+		// A unique result must parse back to data that executes to the same string
+		got, err := tmpl.ParseToMap(s)
+		if err != nil {
+			t.Fatalf("ParseToMap(%q) with '%s' error = %v", s, template, err)
+		}
+		if again, err := tmpl.Execute(got); err != nil || again != s {
+			t.Fatalf("Execute(%v) with '%s' = %q, %v, want %q", got, template, again, err, s)
+		}
+	})
+}
+
+func FuzzParse(f *testing.F) {
+	for i := range fuzzTemplates {
+		for _, input := range []string{"", "a", "log-", "abcdefghijk", "a/b/a.yaml", "x-y.z", "1;2:000003"} {
+			f.Add(uint8(i), input)
+		}
+	}
+
+	templates := make([]Twist, len(fuzzTemplates))
+	for i, template := range fuzzTemplates {
+		templates[i] = MustNew(template, WithOptionalSections([2]string{"[", "]"}))
+	}
+
+	f.Fuzz(func(t *testing.T, i uint8, s string) {
+		tmpl := templates[int(i)%len(templates)]
+		// Every error from parsing is a mismatch, an ambiguity or data that can't be decoded
+		check := func(name string, err error) {
+			if err != nil && !errors.Is(err, ErrTemplateMismatch) && !errors.Is(err, ErrAmbiguousTemplate) && !errors.Is(err, ErrInvalidData) {
+				t.Fatalf("%s(%q) with '%s' error = %v", name, s, tmpl.original, err)
+			}
+		}
+
+		_, err := tmpl.ParseToMap(s)
+		check("ParseToMap", err)
+		var mismatch *MismatchError
+		if errors.As(err, &mismatch) {
+			mismatch.Render()
+		}
+		_, err = tmpl.ParseToMaps(s)
+		check("ParseToMaps", err)
+		for range tmpl.Matches(s) {
+		}
+		var out fuzzData
+		check("Parse", tmpl.Parse(s, &out))
+		_, err = ParseAs[fuzzData](tmpl, s)
+		check("ParseAs", err)
+		_, err = ParseAllAs[*fuzzData](tmpl, s)
+		check("ParseAllAs", err)
+	})
+}
@@ -0,0 +1,114 @@
+package twist
+
+import "strings"
+
+// Data for matching strings against a template that is built once when the template is
+// created, so that it isn't derived again for every string
+type matcher struct {
+	// The variant with every optional section included, see Twist.variant, and the
+	// back-references of it's fields, see Twist.backReferences
+	full variant
+	refs []int
+
+	// Every variant and it's back-references, indexed by the omitted sections
+	variants    []variant
+	variantRefs [][]int
+
+	// Whether a unique match can be found without backtracking, see findUnique
+	linear bool
+}
+
+// Build the matcher for a template
+func (t Twist) compileMatcher() matcher {
+	m := matcher{
+		variants:    make([]variant, t.variantCount()),
+		variantRefs: make([][]int, t.variantCount()),
+	}
+	for omitted := range m.variants {
+		m.variants[omitted] = t.variant(omitted)
+		m.variantRefs[omitted] = t.backReferences(m.variants[omitted])
+	}
+	m.full, m.refs = m.variants[0], m.variantRefs[0]
+
+	m.linear = len(t.sections) == 0 && len(t.fieldParts) > 0
+	for i, fieldIdx := range m.full.fields {
+		m.linear = m.linear && m.refs[i] == -1 && t.fieldParts[fieldIdx].unconstrained()
+	}
+	return m
+}
+
+// Get a variant of the template along with the back-references of it's fields
+func (t Twist) compiledVariant(omitted int) (variant, []int) {
+	if omitted < len(t.matcher.variants) {
+		return t.matcher.variants[omitted], t.matcher.variantRefs[omitted]
+	}
+	v := t.variant(omitted)
+	return v, t.backReferences(v)
+}
+
+// Find whether s has a unique match for the template, returning the only match, the first
+// two matches if it's ambiguous, or a single result holding the error if there is no match.
+//
+// When every field accepts any text, the possible matches are ordered so that the match
+// with each field ending as early as possible and the match with each ending as late as
+// possible bound all others. If they are the same the match is unique, otherwise there are
+// at least two, and both can be found with a single pass over s in each direction.
+func (t Twist) findUnique(s string) []result {
+	if !t.matcher.linear {
+		return t.findFieldIndiciesN(s, 2)
+	}
+	first, ok := t.matcher.earliest(s)
+	if !ok {
+		// Search again to find out why s doesn't match
+		return t.findFieldIndiciesN(s, 1)
+	}
+	last := t.matcher.latest(s)
+	for i := range first {
+		if first[i] != last[i] {
+			return []result{valResult(first), valResult(last)}
+		}
+	}
+	return []result{valResult(first)}
+}
+
+// Find the match where each field ends as early as possible. Returns false if there is no
+// match.
+func (m matcher) earliest(s string) ([][2]int, bool) {
+	pretext := m.full.pretext
+	firstPretext, lastPretext := pretext[0], pretext[len(pretext)-1]
+	if len(s) < len(firstPretext)+len(lastPretext) ||
+		!strings.HasPrefix(s, firstPretext) || !strings.HasSuffix(s, lastPretext) {
+		return nil, false
+	}
+	sEnd := len(s) - len(lastPretext)
+
+	indicies := make([][2]int, len(pretext)-1)
+	start := len(firstPretext)
+	for i := 1; i < len(pretext)-1; i++ {
+		match := strings.Index(s[start:sEnd], pretext[i])
+		if match == -1 {
+			return nil, false
+		}
+		indicies[i-1] = [2]int{start, start + match}
+		start += match + len(pretext[i])
+	}
+	indicies[len(indicies)-1] = [2]int{start, sEnd}
+	return indicies, true
+}
+
+// Find the match where each field ends as late as possible. This must only be used once
+// earliest has found a match, which guarantees there is one.
+func (m matcher) latest(s string) [][2]int {
+	pretext := m.full.pretext
+	sStart := len(pretext[0])
+	end := len(s) - len(pretext[len(pretext)-1])
+
+	indicies := make([][2]int, len(pretext)-1)
+	for i := len(pretext) - 2; i > 0; i-- {
+		match := sStart + strings.LastIndex(s[sStart:end], pretext[i])
+		indicies[i] = [2]int{match + len(pretext[i]), end}
+		end = match
+	}
+	indicies[0] = [2]int{sStart, end}
+	return indicies
+}
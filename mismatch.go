@@ -0,0 +1,100 @@
+package twist
+
+import (
+	"fmt"
+	"strings"
+)
+
+// A MismatchError describes why a string does not match a template. It wraps
+// ErrTemplateMismatch.
+type MismatchError struct {
+	// The string being parsed and the template it was matched against
+	Input    string
+	Template string
+	// The byte offset in Input that matching reached before it failed
+	Offset int
+	// The literal text from the template that was expected at or after Offset, if any
+	Expected string
+	// The field being matched when matching failed, if any
+	Field string
+	// A short description of the mismatch
+	Reason string
+
+	// The byte offset in Template of the part that failed to match
+	templateOffset int
+}
+
+// Construct the error for a string that doesn't match a template at the given offset. field
+// is the index of the field being matched, or -1 if there is none.
+func (t Twist) newMismatchError(s string, reason string, offset int, expected string, field int) *MismatchError {
+	err := &MismatchError{
+		Input:          s,
+		Template:       t.original,
+		Offset:         offset,
+		Expected:       expected,
+		Reason:         reason,
+		templateOffset: min(offset, len(t.original)),
+	}
+	if field != -1 {
+		err.Field = t.fieldParts[field].String()
+		err.templateOffset = t.fieldParts[field].name.start
+	}
+	return err
+}
+
+func (e *MismatchError) Error() string {
+	return fmt.Sprintf("%s: %v", e.Reason, ErrTemplateMismatch)
+}
+
+func (e *MismatchError) Unwrap() error {
+	return ErrTemplateMismatch
+}
+
+// Render shows where matching failed with a caret under the input and the template, e.g.
+//
+//	input:    app-1.txt
+//	               ^
+//	template: {{Name}}-{{Id}}.log
+//	                     ^
+//	expected '.log' after field 'Id'
+func (e *MismatchError) Render() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "input:    %s\n", e.Input)
+	fmt.Fprintf(&b, "          %s^\n", caretPadding(e.Input, e.Offset))
+	fmt.Fprintf(&b, "template: %s\n", e.Template)
+	fmt.Fprintf(&b, "          %s^\n", caretPadding(e.Template, e.templateOffset))
+	switch {
+	case e.Expected != "" && e.Field != "":
+		fmt.Fprintf(&b, "expected '%s' after field '%s'", e.Expected, e.Field)
+	case e.Expected != "":
+		fmt.Fprintf(&b, "expected '%s'", e.Expected)
+	case e.Field != "":
+		fmt.Fprintf(&b, "field '%s' does not match", e.Field)
+	default:
+		b.WriteString(e.Reason)
+	}
+	return b.String()
+}
+
+// The padding needed to put a caret under the character at the byte offset of s. Tabs are
+// kept so that the caret lines up however they are displayed.
+func caretPadding(s string, offset int) string {
+	var b strings.Builder
+	for _, r := range s[:min(max(offset, 0), len(s))] {
+		if r == '\t' {
+			b.WriteRune(r)
+		} else {
+			b.WriteByte(' ')
+		}
+	}
+	return b.String()
+}
+
+// The length of the common prefix of a and b
+func commonPrefix(a, b string) int {
+	n := 0
+	for n < len(a) && n < len(b) && a[n] == b[n] {
+		n++
+	}
+	return n
+}
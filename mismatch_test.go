@@ -0,0 +1,153 @@
+package twist
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+)
+
+func TestMismatchError(t *testing.T) {
+	type testCase struct {
+		name     string
+		template string
+		input    string
+		want     MismatchError
+	}
+
+	tests := []testCase{
+		{
+			name:     "no fields",
+			template: "abc",
+			input:    "abx",
+			want:     MismatchError{Offset: 2, Expected: "abc", Reason: "strings do not match"},
+		},
+		{
+			name:     "start",
+			template: "log-{{Name}}",
+			input:    "lag-app",
+			want:     MismatchError{Offset: 1, Expected: "log-", Reason: "string start does not match template"},
+		},
+		{
+			name:     "end",
+			template: "{{Name}}.log",
+			input:    "app.txt",
+			want:     MismatchError{Offset: 3, Expected: ".log", Field: "Name", Reason: "string end does not match template"},
+		},
+		{
+			name:     "shorter than text",
+			template: "abcdef{{Name}}ghijkl",
+			input:    "abcdefghijk",
+			want:     MismatchError{Offset: 6, Expected: "ghijkl", Field: "Name", Reason: "string end does not match template"},
+		},
+		{
+			name:     "shorter than start",
+			template: "abcdef{{Name}}",
+			input:    "abc",
+			want:     MismatchError{Offset: 3, Expected: "abcdef", Reason: "string start does not match template"},
+		},
+		{
+			name:     "missing separator",
+			template: "{{Name}}-{{Id}}.log",
+			input:    "app_1.log",
+			want:     MismatchError{Offset: 0, Expected: "-", Field: "Name", Reason: "string does not match template"},
+		},
+		{
+			name:     "invalid field",
+			template: "{{Name}}-{{Id:int}}.log",
+			input:    "app-x.log",
+			want:     MismatchError{Offset: 4, Field: "Id", Reason: "string does not match template"},
+		},
+		{
+			name:     "furthest field",
+			template: "{{A:int}}-{{B:int}}-{{C:int}}",
+			input:    "1-2-x",
+			want:     MismatchError{Offset: 4, Field: "C", Reason: "string does not match template"},
+		},
+		{
+			name:     "repeated field",
+			template: "{{Env}}/{{Service}}/{{Env}}.yaml",
+			input:    "prod/a/dev.yaml",
+			want:     MismatchError{Offset: 7, Field: "Env", Reason: "string does not match template"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := MustNew(tt.template).ParseToMap(tt.input)
+			var mismatch *MismatchError
+			if !errors.As(err, &mismatch) {
+				t.Errorf("ParseToMap() error = %v, want a *MismatchError", err)
+				return
+			}
+			if !errors.Is(err, ErrTemplateMismatch) {
+				t.Errorf("ParseToMap() error type = '%v', want type '%v'", err, ErrTemplateMismatch)
+			}
+			tt.want.Input = tt.input
+			tt.want.Template = tt.template
+			if diff := cmp.Diff(*mismatch, tt.want, cmpopts.IgnoreUnexported(MismatchError{})); diff != "" {
+				t.Errorf("ParseToMap() mismatch (-got +want)\n%s", diff)
+			}
+			if want := tt.want.Reason + ": twist error: template mismatch"; err.Error() != want {
+				t.Errorf("ParseToMap() error = '%v', want '%v'", err, want)
+			}
+		})
+	}
+}
+
+func TestMismatchErrorRender(t *testing.T) {
+	type testCase struct {
+		name     string
+		template string
+		input    string
+		want     string
+	}
+
+	tests := []testCase{
+		{
+			name:     "expected text",
+			template: "{{Name}}.log",
+			input:    "app.txt",
+			want: "input:    app.txt\n" +
+				"             ^\n" +
+				"template: {{Name}}.log\n" +
+				"            ^\n" +
+				"expected '.log' after field 'Name'",
+		},
+		{
+			name:     "invalid field",
+			template: "{{Name}}-{{Id:int}}.log",
+			input:    "app-x.log",
+			want: "input:    app-x.log\n" +
+				"              ^\n" +
+				"template: {{Name}}-{{Id:int}}.log\n" +
+				"                     ^\n" +
+				"field 'Id' does not match",
+		},
+		{
+			name:     "start with tabs",
+			template: "\tlog {{Name}}",
+			input:    "\tlag app",
+			want: "input:    \tlag app\n" +
+				"          \t ^\n" +
+				"template: \tlog {{Name}}\n" +
+				"          \t ^\n" +
+				"expected '\tlog '",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := MustNew(tt.template).ParseToMap(tt.input)
+			var mismatch *MismatchError
+			if !errors.As(err, &mismatch) {
+				t.Errorf("ParseToMap() error = %v, want a *MismatchError", err)
+				return
+			}
+			if diff := cmp.Diff(mismatch.Render(), tt.want); diff != "" {
+				t.Errorf("Render() mismatch (-got +want)\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,64 @@
+package twist
+
+// The maximum number of optional sections in a template. Every combination of sections
+// is compiled when the template is created and considered when parsing, so this limits the
+// memory used by a template and the work done for a single string.
+const maxSections = 8
+
+// An optional section of a template. The section is only included when executing if one
+// of it's fields is set and is optional when parsing.
+type section struct {
+	first int // index of the first field in the section
+	last  int // index of the last field in the section
+	start int // offset of the start of the section in the pretext before the first field
+	end   int // offset of the end of the section in the pretext after the last field
+}
+
+// A version of a template with some of it's optional sections left out
+type variant struct {
+	fields  []int // index of each included field in Twist.fieldParts
+	pretext []string
+}
+
+// Construct the variant of the template that leaves out each section whose bit is set in
+// omitted.
+func (t Twist) variant(omitted int) variant {
+	pretext := t.pretext()
+	v := variant{fields: []int{}}
+
+	current := ""
+	pos := 0
+	for i := 0; i <= len(t.fieldParts); {
+		if sec, ok := t.sectionStartingAt(i); ok && omitted&(1<<sec) != 0 {
+			current += pretext[i][pos:t.sections[sec].start]
+			pos = t.sections[sec].end
+			i = t.sections[sec].last + 1
+			continue
+		}
+
+		current += pretext[i][pos:]
+		v.pretext = append(v.pretext, current)
+		if i < len(t.fieldParts) {
+			v.fields = append(v.fields, i)
+		}
+		current = ""
+		pos = 0
+		i++
+	}
+	return v
+}
+
+// Find the section whose first field is at the given index
+func (t Twist) sectionStartingAt(field int) (int, bool) {
+	for i, sec := range t.sections {
+		if sec.first == field {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// Return the number of variants of the template
+func (t Twist) variantCount() int {
+	return 1 << len(t.sections)
+}
@@ -0,0 +1,246 @@
+package twist
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestVariant(t *testing.T) {
+	type testCase struct {
+		name     string
+		template string
+		omitted  int
+		want     variant
+	}
+
+	tests := []testCase{
+		{
+			name:     "no sections",
+			template: "{{A}}-{{B}}",
+			omitted:  0,
+			want:     variant{fields: []int{0, 1}, pretext: []string{"", "-", ""}},
+		},
+		{
+			name:     "section included",
+			template: "{{Name}}[-{{Suffix}}].log",
+			omitted:  0,
+			want:     variant{fields: []int{0, 1}, pretext: []string{"", "-", ".log"}},
+		},
+		{
+			name:     "section omitted",
+			template: "{{Name}}[-{{Suffix}}].log",
+			omitted:  1,
+			want:     variant{fields: []int{0}, pretext: []string{"", ".log"}},
+		},
+		{
+			name:     "section with multiple fields omitted",
+			template: "a[b{{X}}c{{Y}}d]e{{Z}}",
+			omitted:  1,
+			want:     variant{fields: []int{2}, pretext: []string{"ae", ""}},
+		},
+		{
+			name:     "adjacent sections, first omitted",
+			template: "[{{A}}]-[{{B}}]",
+			omitted:  1,
+			want:     variant{fields: []int{1}, pretext: []string{"-", ""}},
+		},
+		{
+			name:     "adjacent sections, second omitted",
+			template: "[{{A}}]-[{{B}}]",
+			omitted:  2,
+			want:     variant{fields: []int{0}, pretext: []string{"", "-"}},
+		},
+		{
+			name:     "adjacent sections, both omitted",
+			template: "[{{A}}]-[{{B}}]",
+			omitted:  3,
+			want:     variant{fields: []int{}, pretext: []string{"-"}},
+		},
+		{
+			name:     "escaped section delimiters",
+			template: "[[{{A}}]][x{{B}}]",
+			omitted:  1,
+			want:     variant{fields: []int{0}, pretext: []string{"[", "]"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpl, err := New(tt.template, WithOptionalSections([2]string{"[", "]"}))
+			if err != nil {
+				t.Errorf("New() error = %v", err)
+				return
+			}
+			got := tmpl.variant(tt.omitted)
+			if diff := cmp.Diff(got, tt.want, cmp.AllowUnexported(variant{})); diff != "" {
+				t.Errorf("variant() mismatch (-got +want)\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestOptionalSectionsError(t *testing.T) {
+	type testCase struct {
+		name      string
+		template  string
+		opts      []twistOption
+		errorType error
+		errorMsg  string
+	}
+
+	sections := WithOptionalSections([2]string{"[", "]"})
+	tests := []testCase{
+		{
+			name:      "nested sections",
+			template:  "[a[{{A}}]]",
+			opts:      []twistOption{sections},
+			errorType: ErrInvalidTemplate,
+			errorMsg:  "nested optional sections",
+		},
+		{
+			name:      "missing end",
+			template:  "[{{A}}",
+			opts:      []twistOption{sections},
+			errorType: ErrInvalidTemplate,
+			errorMsg:  "unmatched optional section delimiters",
+		},
+		{
+			name:      "missing start",
+			template:  "{{A}}]",
+			opts:      []twistOption{sections},
+			errorType: ErrInvalidTemplate,
+			errorMsg:  "unmatched optional section delimiters",
+		},
+		{
+			name:      "no fields",
+			template:  "{{A}}[-]",
+			opts:      []twistOption{sections},
+			errorType: ErrInvalidTemplate,
+			errorMsg:  "optional section has no fields",
+		},
+		{
+			name:      "too many sections",
+			template:  strings.Repeat("[{{A}}]", maxSections+1),
+			opts:      []twistOption{sections},
+			errorType: ErrInvalidTemplate,
+			errorMsg:  "more than 8 optional sections",
+		},
+		{
+			name:      "matching delimiters",
+			template:  "{{A}}",
+			opts:      []twistOption{WithOptionalSections([2]string{"|", "|"})},
+			errorType: ErrInvalidConfig,
+			errorMsg:  "optional section delimeters must not match",
+		},
+		{
+			name:      "empty delimiters",
+			template:  "{{A}}",
+			opts:      []twistOption{WithOptionalSections([2]string{"", "]"})},
+			errorType: ErrInvalidConfig,
+			errorMsg:  "optional section delimeters must not be empty",
+		},
+		{
+			name:      "same as field delimiters",
+			template:  "{{A}}",
+			opts:      []twistOption{WithDelimiters([2]string{"[", "]"}), sections},
+			errorType: ErrInvalidConfig,
+			errorMsg:  "optional section delimeters must not match field delimiters",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := New(tt.template, tt.opts...)
+			if err == nil {
+				t.Errorf("New() error is nil")
+				return
+			}
+			if !errors.Is(err, tt.errorType) {
+				t.Errorf("New() error type = '%v', want type '%v'", err, tt.errorType)
+				return
+			}
+			if !strings.Contains(err.Error(), tt.errorMsg) {
+				t.Errorf("New() error = '%v', want to contain '%v'", err, tt.errorMsg)
+			}
+		})
+	}
+}
+
+func TestOptionalSectionsRoundTrip(t *testing.T) {
+	type LogFile struct {
+		Name   string
+		Suffix int
+	}
+	type testCase struct {
+		name string
+		data LogFile
+		want string
+	}
+
+	tests := []testCase{
+		{
+			name: "section included",
+			data: LogFile{Name: "app", Suffix: 2},
+			want: "app-2.log",
+		},
+		{
+			name: "section omitted",
+			data: LogFile{Name: "app"},
+			want: "app.log",
+		},
+	}
+
+	tmpl := MustNew("{{ Name /[a-z]+/ }}[-{{ Suffix:int }}].log", WithOptionalSections([2]string{"[", "]"}))
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tmpl.Execute(tt.data, WithUnique())
+			if err != nil {
+				t.Errorf("Execute() error = %v", err)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("Execute() = %v, want %v", got, tt.want)
+				return
+			}
+
+			var out LogFile
+			if err := tmpl.Parse(got, &out); err != nil {
+				t.Errorf("Parse() error = %v", err)
+				return
+			}
+			if diff := cmp.Diff(out, tt.data); diff != "" {
+				t.Errorf("Parse() mismatch (-got +want)\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestOptionalSectionsParseToMaps(t *testing.T) {
+	tmpl := MustNew("{{Name}}[-{{Suffix}}].log", WithOptionalSections([2]string{"[", "]"}))
+	got, err := tmpl.ParseToMaps("a-b.log")
+	if err != nil {
+		t.Errorf("ParseToMaps() error = %v", err)
+		return
+	}
+	want := []map[string]string{
+		{"Name": "a", "Suffix": "b"},
+		{"Name": "a-b"},
+	}
+	if diff := cmp.Diff(got, want); diff != "" {
+		t.Errorf("ParseToMaps() mismatch (-got +want)\n%s", diff)
+	}
+
+	// An included section must have a field set
+	got, err = tmpl.ParseToMaps("a-.log")
+	if err != nil {
+		t.Errorf("ParseToMaps() error = %v", err)
+		return
+	}
+	want = []map[string]string{{"Name": "a-"}}
+	if diff := cmp.Diff(got, want); diff != "" {
+		t.Errorf("ParseToMaps() mismatch (-got +want)\n%s", diff)
+	}
+}
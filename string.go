@@ -1,14 +1,30 @@
 package twist
 
 import (
+	"encoding"
 	"errors"
 	"fmt"
 	"reflect"
 )
 
+// Convert a value to a string. Nil values, including nil pointers, are empty. Types that
+// implement encoding.TextUnmarshaler use encoding.TextMarshaler, if they implement it, so
+// they can be parsed back, otherwise fmt.Stringer is used before encoding.TextMarshaler.
 func toString(v interface{}) (string, error) {
-	if reflect.TypeOf(v).Kind() == reflect.Ptr {
-		v = reflect.ValueOf(v).Elem().Interface()
+	if isNil(v) {
+		return "", nil
+	}
+	if stringer, ok := v.(fmt.Stringer); ok && !isTextUnmarshaler(reflect.TypeOf(v)) {
+		return stringer.String(), nil
+	}
+	if text, ok, err := marshalText(v); ok {
+		return text, err
+	}
+	if val := reflect.ValueOf(v); val.Kind() == reflect.Ptr {
+		for val.Kind() == reflect.Ptr {
+			val = val.Elem()
+		}
+		v = val.Interface()
 	}
 	switch val := v.(type) {
 	case string:
@@ -25,3 +41,45 @@ func toString(v interface{}) (string, error) {
 		return "", errors.New("value cannot be converted to string")
 	}
 }
+
+// Check if a value is nil or a pointer that leads to nil, e.g. a **int pointing at a nil *int
+func isNil(v any) bool {
+	if v == nil {
+		return true
+	}
+	val := reflect.ValueOf(v)
+	for val.Kind() == reflect.Ptr {
+		if val.IsNil() {
+			return true
+		}
+		val = val.Elem()
+	}
+	return false
+}
+
+// Convert a value to text if it implements encoding.TextMarshaler with either a value or
+// pointer receiver. Returns false if the value does not implement it.
+func marshalText(v any) (string, bool, error) {
+	val := reflect.ValueOf(v)
+	if val.Kind() == reflect.Ptr && val.IsNil() {
+		return "", false, nil
+	}
+	if !val.Type().Implements(textMarshalerType) {
+		if !reflect.PointerTo(val.Type()).Implements(textMarshalerType) {
+			return "", false, nil
+		}
+		ptr := reflect.New(val.Type())
+		ptr.Elem().Set(val)
+		val = ptr
+	}
+	text, err := val.Interface().(encoding.TextMarshaler).MarshalText()
+	if err != nil {
+		return "", true, err
+	}
+	return string(text), true, nil
+}
+
+var (
+	textMarshalerType   = reflect.TypeFor[encoding.TextMarshaler]()
+	textUnmarshalerType = reflect.TypeFor[encoding.TextUnmarshaler]()
+)
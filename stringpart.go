@@ -44,6 +44,19 @@ func (p strPart) String() string {
 	return p.original[p.start:p.end]
 }
 
+// Return the length of the substring that strPart refers to
+func (p strPart) Len() int {
+	return p.end - p.start
+}
+
+// Construct a new strPart referring to p.String()[start:end]. Panics if out of bounds.
+func (p strPart) Slice(start, end int) strPart {
+	if start < 0 || end > p.Len() {
+		panic(fmt.Errorf("slice out of bounds: %w", errInvalidStrPart))
+	}
+	return mustNewStrPart(p.original, p.start+start, p.start+end)
+}
+
 // Construct a new strPart that has it's whitespace trimmed
 func (p strPart) TrimSpace() strPart {
 	start := p.start
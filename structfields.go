@@ -0,0 +1,140 @@
+package twist
+
+import (
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// The struct tag used to give a struct field a different name in templates. A tag of "-"
+// means the struct field is never used.
+const tagName = "twist"
+
+// The results of lookupField, which are cached as finding the fields of a struct is slow
+var fieldCache sync.Map
+
+type fieldCacheKey struct {
+	t    reflect.Type
+	name string
+}
+
+type fieldCacheEntry struct {
+	field reflect.StructField
+	ok    bool
+}
+
+// Find the field of a struct with the given name. A field is named by it's `twist` tag or,
+// if it has no tag, by it's Go name. Tagged fields take precedence and unexported fields are
+// ignored.
+func lookupField(t reflect.Type, name string) (reflect.StructField, bool) {
+	key := fieldCacheKey{t: t, name: name}
+	if entry, ok := fieldCache.Load(key); ok {
+		return entry.(fieldCacheEntry).field, entry.(fieldCacheEntry).ok
+	}
+	f, ok := findField(t, name)
+	fieldCache.Store(key, fieldCacheEntry{field: f, ok: ok})
+	return f, ok
+}
+
+// Find the field of a struct with the given name without using the cache, see lookupField
+func findField(t reflect.Type, name string) (reflect.StructField, bool) {
+	var untagged *reflect.StructField
+	for _, f := range reflect.VisibleFields(t) {
+		if !f.IsExported() {
+			continue
+		}
+		if tag, ok := f.Tag.Lookup(tagName); ok {
+			if tagged, _, _ := strings.Cut(tag, ","); tagged == name && tagged != "-" {
+				return f, true
+			}
+		} else if f.Name == name && untagged == nil {
+			untagged = &f
+		}
+	}
+	if untagged == nil {
+		return reflect.StructField{}, false
+	}
+	return *untagged, true
+}
+
+// Find the value of the field of a struct with the given name, see lookupField. Returns an
+// invalid value if there is no such field.
+func fieldByName(v reflect.Value, name string) reflect.Value {
+	f, ok := lookupField(v.Type(), name)
+	if !ok {
+		return reflect.Value{}
+	}
+	value, err := v.FieldByIndexErr(f.Index)
+	if err != nil {
+		return reflect.Value{}
+	}
+	return value
+}
+
+// Find the value at a dotted path, e.g. 'Owner.Team.Name', by walking through nested
+// structs, maps, pointers and interfaces. Returns false if any part of the path is missing.
+func lookupPath(v reflect.Value, path string) (reflect.Value, bool) {
+	for path != "" {
+		for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+			if v.IsNil() {
+				return reflect.Value{}, false
+			}
+			v = v.Elem()
+		}
+
+		segment, rest, _ := strings.Cut(path, ".")
+		switch v.Kind() {
+		case reflect.Struct:
+			v = fieldByName(v, segment)
+		case reflect.Map:
+			keyType := v.Type().Key()
+			if keyType.Kind() != reflect.String {
+				return reflect.Value{}, false
+			}
+			// Prefer the whole of the remaining path as a key so that flat maps, such as
+			// those returned by ParseToMap, can be used.
+			if value := v.MapIndex(reflect.ValueOf(path).Convert(keyType)); value.IsValid() {
+				v, rest = value, ""
+			} else {
+				v = v.MapIndex(reflect.ValueOf(segment).Convert(keyType))
+			}
+		default:
+			return reflect.Value{}, false
+		}
+
+		if !v.IsValid() {
+			return reflect.Value{}, false
+		}
+		path = rest
+	}
+	return v, true
+}
+
+// Find the type of the value at a dotted path, see lookupPath. Map elements are looked up one
+// segment at a time, as they are when decoding. Returns false if any part of the path is
+// missing. The path can't be followed through an interface so its type is returned instead.
+func lookupPathType(t reflect.Type, path string) (reflect.Type, bool) {
+	for path != "" {
+		for t.Kind() == reflect.Ptr {
+			t = t.Elem()
+		}
+
+		segment, rest, _ := strings.Cut(path, ".")
+		switch {
+		case t.Kind() == reflect.Struct:
+			f, ok := lookupField(t, segment)
+			if !ok {
+				return nil, false
+			}
+			t = f.Type
+		case t.Kind() == reflect.Map && t.Key().Kind() == reflect.String:
+			t = t.Elem()
+		case t.Kind() == reflect.Interface:
+			return t, true
+		default:
+			return nil, false
+		}
+		path = rest
+	}
+	return t, true
+}
@@ -0,0 +1,57 @@
+package twist
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestLookupField(t *testing.T) {
+	type Embedded struct {
+		Promoted string
+	}
+	type data struct {
+		Embedded
+		Name     string
+		Tagged   string `twist:"region"`
+		Ignored  string `twist:"-"`
+		Shadowed string
+		Shadow   string `twist:"Shadowed"`
+		Options  string `twist:"opts,omitempty"`
+		private  string
+	}
+
+	type testCase struct {
+		name      string
+		fieldName string
+		want      []int
+		wantOk    bool
+	}
+
+	tests := []testCase{
+		{name: "untagged", fieldName: "Name", want: []int{1}, wantOk: true},
+		{name: "tagged", fieldName: "region", want: []int{2}, wantOk: true},
+		{name: "tagged go name", fieldName: "Tagged", wantOk: false},
+		{name: "ignored", fieldName: "Ignored", wantOk: false},
+		{name: "ignored tag", fieldName: "-", wantOk: false},
+		{name: "tag takes precedence", fieldName: "Shadowed", want: []int{5}, wantOk: true},
+		{name: "tag with options", fieldName: "opts", want: []int{6}, wantOk: true},
+		{name: "unexported", fieldName: "private", wantOk: false},
+		{name: "promoted", fieldName: "Promoted", want: []int{0, 0}, wantOk: true},
+		{name: "missing", fieldName: "Missing", wantOk: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := lookupField(reflect.TypeFor[data](), tt.fieldName)
+			if ok != tt.wantOk {
+				t.Errorf("lookupField() ok = %v, want %v", ok, tt.wantOk)
+				return
+			}
+			if diff := cmp.Diff(got.Index, tt.want); ok && diff != "" {
+				t.Errorf("lookupField() mismatch (-got +want)\n%s", diff)
+			}
+		})
+	}
+}
@@ -1,7 +1,9 @@
 package twist
 
 import (
+	"context"
 	"fmt"
+	"iter"
 	"reflect"
 	"strings"
 )
@@ -9,8 +11,16 @@ import (
 // Twist - a reversible template
 type Twist struct {
 	original     string
-	fieldParts   []strPart
+	fieldParts   []field
 	pretextParts []strPart
+	sections     []section
+	codecs       map[reflect.Type]*codec
+	matcher      matcher
+	// The fields by name and the field names in template order, see decoder
+	fieldsByName map[string]field
+	fieldNames   []string
+	maxMatches   int
+	allErrors    bool
 }
 
 func (t Twist) fields() []string {
@@ -31,118 +41,255 @@ func (t Twist) pretext() []string {
 
 func (t Twist) execute(data any) (string, error) {
 	fields := t.fields()
-	pretext := t.pretext()
 
-	// Convert data to a map[string]string
-	dataMap := make(map[string]string)
+	// Collect the value of every field in data
+	values := make(map[string]any)
 
 	v := reflect.ValueOf(data)
 	if v.Kind() == reflect.Ptr {
 		v = v.Elem()
 	}
 
-	switch v.Kind() {
-	case reflect.Struct:
-		for _, field := range fields {
-			value := v.FieldByName(field)
-			if !value.IsValid() {
-				return "", fmt.Errorf("field '%s' is missing: %w", field, ErrInvalidData)
-			}
-			stringValue, err := toString(value.Interface())
-			if err != nil {
-				return "", fmt.Errorf("field '%s' is not stringable: %w", field, ErrInvalidData)
+	if v.Kind() != reflect.Struct && v.Kind() != reflect.Map {
+		return "", fmt.Errorf("data is not a struct or map: %w", ErrInvalidData)
+	}
+	errs := fieldErrors{all: t.allErrors}
+	for _, field := range fields {
+		value, ok := lookupPath(v, field)
+		if ok {
+			values[field] = value.Interface()
+		} else if v.Kind() == reflect.Struct {
+			if errs.add(&FieldError{Field: field, Reason: "is missing", err: ErrInvalidData}) {
+				return "", errs.join(fields)
 			}
-			dataMap[field] = stringValue
 		}
-	case reflect.Map:
-		for _, key := range v.MapKeys() {
-			val := v.MapIndex(key)
-			stringValue, err := toString(val.Interface())
-			if err != nil {
-				return "", fmt.Errorf("field '%s' is not stringable: %w", key.String(), ErrInvalidData)
-			}
-			dataMap[key.String()] = stringValue
+	}
+
+	// Leave out any optional sections where none of the fields are set
+	omitted := 0
+	for i, sec := range t.sections {
+		set := false
+		for _, field := range fields[sec.first : sec.last+1] {
+			value, ok := values[field]
+			set = set || (ok && !isZero(value))
+		}
+		if !set {
+			omitted |= 1 << i
 		}
-	default:
-		return "", fmt.Errorf("data is not a struct or map: %w", ErrInvalidData)
 	}
+	variant, _ := t.compiledVariant(omitted)
 
 	// Construct the result string
 	var result string
-	for i, field := range fields {
+	for i, fieldIdx := range variant.fields {
 		// access a variable dynamically from any object of type any
-		dataField, ok := dataMap[field]
+		field := t.fieldParts[fieldIdx]
+		value, ok := values[field.String()]
 		if !ok {
-			return "", fmt.Errorf("field '%s' is missing: %w", field, ErrInvalidData)
+			if errs.add(&FieldError{Field: field.String(), Reason: "is missing", err: ErrInvalidData}) {
+				return "", errs.join(fields)
+			}
+			continue
+		}
+		dataField, err := field.format(value, t.codecs)
+		if err != nil {
+			if errs.add(&FieldError{Field: field.String(), Value: fmt.Sprint(value), Kind: typeName(value), Reason: err.Error(), err: ErrInvalidData}) {
+				return "", errs.join(fields)
+			}
+			continue
+		}
+		if valid, reason := field.validate(dataField); !valid {
+			if errs.add(&FieldError{Field: field.String(), Value: dataField, Kind: typeName(value), Reason: reason, err: ErrInvalidData}) {
+				return "", errs.join(fields)
+			}
+			continue
 		}
-		result += fmt.Sprintf("%s%s", pretext[i], dataField)
+		result += fmt.Sprintf("%s%s", variant.pretext[i], dataField)
+	}
+	if err := errs.join(fields); err != nil {
+		return "", err
 	}
-	result += pretext[len(pretext)-1]
+	result += variant.pretext[len(variant.pretext)-1]
 	return result, nil
 }
 
+// Check if a value is nil or the zero value for it's type
+func isZero(v any) bool {
+	return v == nil || reflect.ValueOf(v).IsZero()
+}
+
+// The number of steps between checks of whether a search's context is done
+const cancelCheckInterval = 256
+
 type result struct {
 	val [][2]int
 	err error
 }
 
-func errResult(msg string) result {
-	return result{val: nil, err: fmt.Errorf("%s: %w", msg, ErrTemplateMismatch)}
+func errResult(err *MismatchError) result {
+	return result{val: nil, err: err}
 }
 
 func valResult(val [][2]int) result {
 	return result{val: val, err: nil}
 }
 
-func (t Twist) findFieldIndicies(s string) <-chan result {
-	runSearch := true
-	ch := make(chan result, 3)
-	pretext := t.pretext()
-	resultCount := 0
+// Find the start and end index of every field in s for every way that s can match the
+// template. Fields in optional sections that are left out have the indicies {-1, -1}. If
+// there are no matches a single result holding the error is yielded instead. The search
+// stops as soon as the caller stops iterating.
+func (t Twist) findFieldIndicies(s string) iter.Seq[result] {
+	return t.findFieldIndiciesContext(context.Background(), s)
+}
+
+// Find the indicies of every field in s, see findFieldIndicies, stopping the search with an
+// error result if the context is done.
+func (t Twist) findFieldIndiciesContext(ctx context.Context, s string) iter.Seq[result] {
+	return func(yield func(result) bool) {
+		var err *MismatchError
+		resultCount := 0
+
+		for omitted := 0; omitted < t.variantCount(); omitted++ {
+			variant, refs := t.compiledVariant(omitted)
+			stopped := false
+			variantErr := t.searchVariant(ctx, s, variant, refs, func(val [][2]int) bool {
+				indicies := make([][2]int, len(t.fieldParts))
+				for i := range indicies {
+					indicies[i] = [2]int{-1, -1}
+				}
+				for i, fieldIdx := range variant.fields {
+					indicies[fieldIdx] = val[i]
+				}
+
+				// An included section must have at least one field set, otherwise it
+				// would have been left out when executing.
+				for i, sec := range t.sections {
+					set := omitted&(1<<i) != 0
+					for _, idx := range indicies[sec.first : sec.last+1] {
+						set = set || idx[1] > idx[0]
+					}
+					if !set {
+						return true
+					}
+				}
+
+				resultCount++
+				stopped = !yield(valResult(indicies))
+				return !stopped
+			})
+			if stopped {
+				return
+			}
+			if ctxErr := ctx.Err(); ctxErr != nil {
+				yield(result{val: nil, err: fmt.Errorf("search stopped: %w", ctxErr)})
+				return
+			}
+			// Report the variant that got furthest through the string
+			if err == nil || variantErr != nil && variantErr.Offset > err.Offset {
+				err = variantErr
+			}
+		}
+
+		if resultCount < 1 {
+			if err == nil {
+				err = t.newMismatchError(s, "string does not match template", 0, "", -1)
+			}
+			yield(errResult(err))
+		}
+	}
+}
+
+// Collect at most n results from findFieldIndicies
+func (t Twist) findFieldIndiciesN(s string, n int) []result {
+	var results []result
+	for result := range t.findFieldIndicies(s) {
+		results = append(results, result)
+		if len(results) == n {
+			break
+		}
+	}
+	return results
+}
+
+// Search for every way that s matches a variant of the template, calling yield with the
+// indicies of the variant's fields for each match until it returns false or the context is
+// done. refs are the back-references of the variant's fields. If there are no matches the
+// error describes how far through s matching got.
+func (t Twist) searchVariant(ctx context.Context, s string, variant variant, refs []int, yield func([][2]int) bool) *MismatchError {
+	pretext := variant.pretext
 	var sEnd int
 	var lastPretext string
 
 	// If there are any fields, these will be at least 2 pretexts
 	if len(pretext) <= 1 {
 		if s == pretext[0] {
-			ch <- valResult([][2]int{})
-		} else {
-			ch <- errResult("strings do not match")
+			yield([][2]int{})
+			return nil
 		}
-		runSearch = false
-	} else {
-		// Verify the first pretexts match and then they can be
-		firstPretext := pretext[0]
-		if firstPretext != s[:len(firstPretext)] {
-			ch <- errResult("string start does not match template")
-			runSearch = false
-		} else {
-			// The last pretext can never be part of the match so check that it matches
-			// and then exclude from all searches.
-			lastPretext = pretext[len(pretext)-1]
-			sEnd = len(s) - len(lastPretext)
-			if s[sEnd:] != lastPretext {
-				ch <- errResult("string end does not match template")
-				runSearch = false
-			}
+		return t.newMismatchError(s, "strings do not match", commonPrefix(s, pretext[0]), pretext[0], -1)
+	}
+
+	// Verify the first pretexts match and then they can be
+	firstPretext := pretext[0]
+	if !strings.HasPrefix(s, firstPretext) {
+		return t.newMismatchError(s, "string start does not match template", commonPrefix(s, firstPretext), firstPretext, -1)
+	}
+
+	// The last pretext can never be part of the match so check that it matches
+	// and then exclude from all searches. It must not overlap the first pretext.
+	lastPretext = pretext[len(pretext)-1]
+	sEnd = len(s) - len(lastPretext)
+	if sEnd < len(firstPretext) || s[sEnd:] != lastPretext {
+		return t.newMismatchError(s, "string end does not match template", max(sEnd, len(firstPretext)), lastPretext, variant.fields[len(variant.fields)-1])
+	}
+
+	// The furthest point through s reached by the search, reported if there are no matches
+	var furthest *MismatchError
+	found := false
+	reached := func(offset int, expected string, k int) {
+		if furthest == nil || offset > furthest.Offset {
+			furthest = t.newMismatchError(s, "string does not match template", offset, expected, variant.fields[k])
+		}
+	}
+
+	// Check the text matched by the field at index k of the variant is valid for the field.
+	// Repeated fields are back-references so must match the text of their first occurrence.
+	valid := func(k int, result [][2]int) bool {
+		text := s[result[k][0]:result[k][1]]
+		if ref := refs[k]; ref != -1 && s[result[ref][0]:result[ref][1]] != text {
+			return false
 		}
+		return t.fieldParts[variant.fields[k]].accepts(text)
+	}
+
+	// Checking the context is relatively slow so it's only checked every so many steps
+	done := ctx.Done()
+	steps := 0
+	cancelled := func() bool {
+		steps++
+		return done != nil && steps%cancelCheckInterval == 0 && ctx.Err() != nil
 	}
 
-	// Function to recursively search for possible pretext matches.
-	var search func(start, pretext int, result [][2]int)
-	search = func(start, pretextIdx int, result [][2]int) {
+	// Function to recursively search for possible pretext matches. Returns false once the
+	// search has been stopped.
+	var search func(start, pretext int, result [][2]int) bool
+	search = func(start, pretextIdx int, result [][2]int) bool {
 		// If we've matched all pretexts, send the result and then return so that we can
 		// look for other potential matches.
 		if pretextIdx == len(pretext)-1 {
 			result[pretextIdx-1][1] = sEnd
-			var resultCopy = make([][2]int, len(result))
-			copy(resultCopy, result)
-			ch <- valResult(resultCopy)
-			resultCount++
-			return
+			if !valid(pretextIdx-1, result) {
+				reached(result[pretextIdx-1][0], "", pretextIdx-1)
+				return true
+			}
+			found = true
+			return yield(result)
 		}
 
 		for i := 0; i+start <= sEnd; {
+			if cancelled() {
+				return false
+			}
 			pretextStr := pretext[pretextIdx]
 			offset := start + i
 			match := strings.Index(s[offset:sEnd], pretextStr)
@@ -150,40 +297,63 @@ func (t Twist) findFieldIndicies(s string) <-chan result {
 
 			// Stop searching this path
 			if match == -1 {
-				return
+				if pretextIdx > 0 {
+					reached(start, pretextStr, pretextIdx-1)
+				}
+				return true
 			}
 
 			// Store the start of this match
 			indexStart := match + offset + len(pretextStr)
 			result = append(result, [2]int{indexStart, 0})
 
-			// Store the end for the previous match
+			// Store the end for the previous match and skip it if the text isn't valid for
+			// the field.
 			if pretextIdx > 0 {
 				result[pretextIdx-1][1] = match + offset
+				if !valid(pretextIdx-1, result) {
+					reached(result[pretextIdx-1][0], "", pretextIdx-1)
+					result = result[:len(result)-1]
+					continue
+				}
 			}
 
 			// Search for the next pretext
-			search(indexStart, pretextIdx+1, result)
+			if !search(indexStart, pretextIdx+1, result) {
+				return false
+			}
 			result = result[:len(result)-1]
 
 			// The first match is fixed so don't consider other options
 			if pretextIdx == 0 {
-				return
+				return true
 			}
 		}
+		return true
 	}
 
-	if runSearch {
-		go func() {
-			search(0, 0, [][2]int{})
-			if resultCount < 1 {
-				ch <- errResult("string does not match template")
-			}
-			close(ch)
-		}()
-	} else {
-		close(ch)
+	search(0, 0, [][2]int{})
+	if found {
+		return nil
 	}
+	if furthest == nil {
+		furthest = t.newMismatchError(s, "string does not match template", len(firstPretext), "", -1)
+	}
+	return furthest
+}
 
-	return ch
+// For each field in a variant find the index of the first field in the variant with the
+// same name, or -1 if this is the first field with that name.
+func (t Twist) backReferences(variant variant) []int {
+	refs := make([]int, len(variant.fields))
+	for i, fieldIdx := range variant.fields {
+		refs[i] = -1
+		for j, otherIdx := range variant.fields[:i] {
+			if t.fieldParts[otherIdx].name.Matches(t.fieldParts[fieldIdx].name) {
+				refs[i] = j
+				break
+			}
+		}
+	}
+	return refs
 }
@@ -3,6 +3,7 @@ package twist
 import (
 	"errors"
 	"fmt"
+	"net/netip"
 	"strings"
 	"testing"
 	"time"
@@ -10,6 +11,33 @@ import (
 	"github.com/google/go-cmp/cmp"
 )
 
+// A colour implementing encoding.TextMarshaler and encoding.TextUnmarshaler with pointer
+// receivers
+type hexColor struct {
+	r, g, b uint8
+}
+
+func (c *hexColor) MarshalText() ([]byte, error) {
+	return fmt.Appendf(nil, "#%02x%02x%02x", c.r, c.g, c.b), nil
+}
+
+func (c *hexColor) UnmarshalText(text []byte) error {
+	_, err := fmt.Sscanf(string(text), "#%02x%02x%02x", &c.r, &c.g, &c.b)
+	return err
+}
+
+// A type implementing fmt.Stringer and encoding.TextMarshaler, but not
+// encoding.TextUnmarshaler
+type stringMarshaler struct{}
+
+func (stringMarshaler) String() string {
+	return "string"
+}
+
+func (stringMarshaler) MarshalText() ([]byte, error) {
+	return []byte("text"), nil
+}
+
 func TestTwistExceuteSuccess(t *testing.T) {
 	type testCase struct {
 		name     string
@@ -19,6 +47,7 @@ func TestTwistExceuteSuccess(t *testing.T) {
 	}
 
 	timeNow := time.Now()
+	addr := netip.MustParseAddr("192.168.0.1")
 	hello := "hello"
 	data := map[string]any{"Name": "World"}
 
@@ -87,6 +116,8 @@ func TestTwistExceuteSuccess(t *testing.T) {
 			want: "1 2 3 4 5 6 7 8 9 10 11.1",
 		},
 		{
+			// time.Time implements encoding.TextUnmarshaler so it is written with MarshalText,
+			// rather than String, so it can be parsed back
 			name:     "stringer",
 			template: "{{Stringer}}",
 			data: struct {
@@ -94,7 +125,41 @@ func TestTwistExceuteSuccess(t *testing.T) {
 			}{
 				Stringer: timeNow,
 			},
-			want: timeNow.String(),
+			want: timeNow.Format(time.RFC3339Nano),
+		},
+		{
+			name:     "stringer and text marshaler",
+			template: "{{Month}} {{Both}}",
+			data: struct {
+				Month fmt.Stringer
+				Both  stringMarshaler
+			}{
+				Month: time.March,
+				Both:  stringMarshaler{},
+			},
+			want: "March string",
+		},
+		{
+			name:     "text marshaler",
+			template: "{{Addr}}",
+			data: struct {
+				Addr *netip.Addr
+			}{
+				Addr: &addr,
+			},
+			want: "192.168.0.1",
+		},
+		{
+			name:     "text marshaler (pointer receiver)",
+			template: "{{Color}} {{Pointer}}",
+			data: struct {
+				Color   hexColor
+				Pointer *hexColor
+			}{
+				Color:   hexColor{r: 255, g: 1},
+				Pointer: &hexColor{b: 16},
+			},
+			want: "#ff0100 #000010",
 		},
 		{
 			name:     "pointer",
@@ -112,6 +177,79 @@ func TestTwistExceuteSuccess(t *testing.T) {
 			data:     map[string]string{"Greeting": "Hello"},
 			want:     "Hello World",
 		},
+		{
+			name:     "lists",
+			template: "{{Tags...}} {{Ids...:%02d sep=\"-\"}} {{Joined...}} {{Empty...}}",
+			data: struct {
+				Tags   []string
+				Ids    []int
+				Joined string
+				Empty  []bool
+			}{Tags: []string{"a", "b"}, Ids: []int{1, 2, 3}, Joined: "c,d", Empty: []bool{}},
+			want: "a,b 01-02-03 c,d ",
+		},
+		{
+			name:     "nested",
+			template: "{{Owner.Team.Name}}/{{Owner.Labels.Env}}/{{Source.Path}}",
+			data: map[string]any{
+				"Owner": &struct {
+					Team   struct{ Name string }
+					Labels map[string]string
+				}{
+					Team:   struct{ Name string }{Name: "core"},
+					Labels: map[string]string{"Env": "prod"},
+				},
+				"Source": map[string]any{"Path": "a/b"},
+			},
+			want: "core/prod/a/b",
+		},
+		{
+			name:     "nested flat map",
+			template: "{{Owner.Team.Name}}",
+			data:     map[string]string{"Owner.Team.Name": "core"},
+			want:     "core",
+		},
+		{
+			name:     "escaped delimiters",
+			template: "{{{{ {{Name}} }}}}",
+			data:     map[string]string{"Name": "World"},
+			want:     "{{ World }}",
+		},
+		{
+			name:     "format verbs",
+			template: "{{Seq:%06d}}-{{Price:%.2f}}-{{Hex:%x}}-{{Name:%-5s}}|{{Ok:%t}}",
+			data: struct {
+				Seq   int
+				Price float64
+				Hex   uint8
+				Name  string
+				Ok    bool
+			}{Seq: 42, Price: 3.14159, Hex: 255, Name: "ab", Ok: true},
+			want: "000042-3.14-ff-ab   |true",
+		},
+		{
+			name:     "nil values",
+			template: "{{Name}}-{{Age}}-{{Note}}-{{Color}}.",
+			data: struct {
+				Name  *string
+				Age   *int
+				Note  any
+				Color *hexColor
+			}{},
+			want: "---.",
+		},
+		{
+			name:     "nil in map",
+			template: "{{Name}}|{{Tags...}}",
+			data:     map[string]any{"Name": nil, "Tags": []*string{&hello, nil}},
+			want:     "|hello,",
+		},
+		{
+			name:     "format verbs with strings",
+			template: "{{Seq:%06d}}-{{Price:%.2f}}",
+			data:     map[string]string{"Seq": "-42", "Price": "1.5"},
+			want:     "-00042-1.50",
+		},
 	}
 
 	for _, tt := range tests {
@@ -181,6 +319,78 @@ func TestTwistExecuteError(t *testing.T) {
 			errorType: ErrInvalidData,
 			errorMsg:  "field 'NotStringable' is not stringable",
 		},
+		{
+			name:      "wrong type",
+			template:  "{{Age:int}}",
+			data:      map[string]any{"Age": "twenty"},
+			errorType: ErrInvalidData,
+			errorMsg:  "field 'Age' is not a valid int",
+		},
+		{
+			name:      "nil typed field",
+			template:  "{{Age:int}}",
+			data:      struct{ Age *int }{},
+			errorType: ErrInvalidData,
+			errorMsg:  "field 'Age' is not a valid int",
+		},
+		{
+			name:      "pattern mismatch",
+			template:  "{{Id /[0-9a-f]{8}/}}",
+			data:      map[string]any{"Id": "0BADF00D"},
+			errorType: ErrInvalidData,
+			errorMsg:  "field 'Id' does not match the field's pattern",
+		},
+		{
+			name:     "missing nested field (struct)",
+			template: "Hello, {{Owner.Name}}",
+			data: struct {
+				Owner *struct{ Name string }
+			}{},
+			errorType: ErrInvalidData,
+			errorMsg:  "field 'Owner.Name' is missing",
+		},
+		{
+			name:      "missing nested field (map)",
+			template:  "Hello, {{Owner.Name}}",
+			data:      map[string]any{"Owner": map[string]any{}},
+			errorType: ErrInvalidData,
+			errorMsg:  "field 'Owner.Name' is missing",
+		},
+		{
+			name:      "not a slice",
+			template:  "{{Tags...}}",
+			data:      map[string]any{"Tags": 1},
+			errorType: ErrInvalidData,
+			errorMsg:  "field 'Tags' is not a slice",
+		},
+		{
+			name:      "element contains separator",
+			template:  "{{Tags...}}",
+			data:      map[string]any{"Tags": []string{"a", "b,c"}},
+			errorType: ErrInvalidData,
+			errorMsg:  "field 'Tags' has an element containing the separator ','",
+		},
+		{
+			name:      "invalid element",
+			template:  "{{Tags...:int}}",
+			data:      map[string]any{"Tags": []string{"1", "b"}},
+			errorType: ErrInvalidData,
+			errorMsg:  "field 'Tags' has an element that is not a valid int",
+		},
+		{
+			name:      "cannot be formatted (string)",
+			template:  "{{Seq:%06d}}",
+			data:      map[string]any{"Seq": "abc"},
+			errorType: ErrInvalidData,
+			errorMsg:  "field 'Seq' cannot be formatted with '%06d'",
+		},
+		{
+			name:      "cannot be formatted (kind)",
+			template:  "{{Seq:%06d}}",
+			data:      map[string]any{"Seq": 1.5},
+			errorType: ErrInvalidData,
+			errorMsg:  "field 'Seq' cannot be formatted with '%06d'",
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -283,6 +493,45 @@ func TestFindFieldIndiciesSucess(t *testing.T) {
 				{{3, 11}, {11, 11}},
 			},
 		},
+		{
+			name:     "typed fields, no separator",
+			template: "...{{Name:bool}}{{Age:int}}...",
+			result:   "...true12...",
+			want:     [][][2]int{{{3, 7}, {7, 9}}},
+		},
+		{
+			name:     "typed fields, common separator",
+			template: "{{First:int}}-{{Second:int}}",
+			result:   "-1--2",
+			want:     [][][2]int{{{0, 2}, {3, 5}}},
+		},
+		{
+			name:     "repeated fields",
+			template: "{{Env}}/{{Service}}/{{Env}}.yaml",
+			result:   "prod/a/b/prod.yaml",
+			want:     [][][2]int{{{0, 4}, {5, 8}, {9, 13}}},
+		},
+		{
+			name:     "repeated fields, no separator",
+			template: "{{A}}{{A}}{{B}}",
+			result:   "abab",
+			want: [][][2]int{
+				{{0, 0}, {0, 0}, {0, 4}},
+				{{0, 2}, {2, 4}, {4, 4}},
+			},
+		},
+		{
+			name:     "format verbs, no separator",
+			template: "{{First:%03d}}{{Second:%03d}}",
+			result:   "001002",
+			want:     [][][2]int{{{0, 3}, {3, 6}}},
+		},
+		{
+			name:     "format verbs, common separator",
+			template: "{{First:%.1f}}.{{Second}}",
+			result:   "1.5.txt",
+			want:     [][][2]int{{{0, 3}, {4, 7}}},
+		},
 	}
 
 	for _, tt := range tests {
@@ -292,9 +541,8 @@ func TestFindFieldIndiciesSucess(t *testing.T) {
 				t.Errorf("New() error = %v", err)
 				return
 			}
-			ch := tmpl.findFieldIndicies(tt.result)
 			results := [][][2]int{}
-			for result := range ch {
+			for result := range tmpl.findFieldIndicies(tt.result) {
 				if result.err != nil {
 					t.Errorf("template mismatch: %v", result.err)
 					return
@@ -347,6 +595,13 @@ func TestFindFieldIndiciesError(t *testing.T) {
 			errorType: ErrTemplateMismatch,
 			errorMsg:  "string does not match template",
 		},
+		{
+			name:      "repeated fields differ",
+			template:  "{{Env}}/{{Service}}/{{Env}}.yaml",
+			result:    "prod/a/dev.yaml",
+			errorType: ErrTemplateMismatch,
+			errorMsg:  "string does not match template",
+		},
 		{
 			name:      "empty template",
 			template:  "",
@@ -369,12 +624,12 @@ func TestFindFieldIndiciesError(t *testing.T) {
 				t.Errorf("New() error = %v", err)
 				return
 			}
-			ch := tmpl.findFieldIndicies(tt.result)
-			chResult, ok := <-ch
-			err = chResult.err
-			if !ok {
-				t.Errorf("findFieldIndicies() channel empty")
+			results := tmpl.findFieldIndiciesN(tt.result, 2)
+			if len(results) != 1 {
+				t.Errorf("findFieldIndicies() results = %v, want 1", len(results))
+				return
 			}
+			err = results[0].err
 			if err == nil {
 				t.Errorf("findFieldIndicies() error is nil")
 				return
@@ -390,3 +645,132 @@ func TestFindFieldIndiciesError(t *testing.T) {
 		})
 	}
 }
+
+func TestFindUnique(t *testing.T) {
+	templates := []string{
+		"{{A}}",
+		"{{A}}-{{B}}",
+		"x{{A}}{{B}}y",
+		"{{A}}--{{B}}-{{C}}.log",
+		"{{A}}/{{B...}}/{{C}}",
+		"ab{{A}}ab{{B}}ab",
+	}
+	inputs := []string{
+		"--", "a-b", "a-b-c", "a--b-c.log", "a---b-c-d.log", "--.log", "a/b/c", "a/b,c/d/e",
+		"xy", "xay", "xaby", "ababab", "abababab", "abXabYab", "abab", "ab",
+	}
+
+	for _, template := range templates {
+		tmpl := MustNew(template)
+		if !tmpl.matcher.linear {
+			t.Errorf("template '%s' does not use the linear matcher", template)
+			continue
+		}
+		for _, input := range inputs {
+			got := tmpl.findUnique(input)
+			want := tmpl.findFieldIndiciesN(input, 2)
+			if len(got) != len(want) {
+				t.Errorf("findUnique(%q) with '%s' = %v results, want %v", input, template, len(got), len(want))
+				continue
+			}
+			if diff := cmp.Diff(got[0].val, want[0].val); diff != "" {
+				t.Errorf("findUnique(%q) with '%s' mismatch (-got +want)\n%s", input, template, diff)
+			}
+			if (got[0].err == nil) != (want[0].err == nil) {
+				t.Errorf("findUnique(%q) with '%s' error = %v, want %v", input, template, got[0].err, want[0].err)
+			}
+		}
+	}
+
+	// Constraints, repeated fields and optional sections need the full search
+	for _, template := range []string{"{{A:int}}-{{B}}", "{{A}}-{{A}}", "{{A}}[-{{B}}]", "prefix"} {
+		if MustNew(template, WithOptionalSections([2]string{"[", "]"})).matcher.linear {
+			t.Errorf("template '%s' uses the linear matcher", template)
+		}
+	}
+}
+
+func BenchmarkFindUnique(b *testing.B) {
+	tmpl := MustNew("s3://{{Bucket}}/{{Prefix}}/dt={{Date}}/{{Name}}.parquet")
+	input := "s3://analytics/" + strings.Repeat("events/", 20) + "raw/dt=2024-01-02/part-00001.parquet"
+
+	b.Run("linear", func(b *testing.B) {
+		b.ReportAllocs()
+		for b.Loop() {
+			tmpl.findUnique(input)
+		}
+	})
+	b.Run("backtracking", func(b *testing.B) {
+		b.ReportAllocs()
+		for b.Loop() {
+			tmpl.findFieldIndiciesN(input, 2)
+		}
+	})
+}
+
+func BenchmarkParseToMap(b *testing.B) {
+	benchmarks := []struct {
+		name     string
+		template string
+		input    string
+		wantErr  error
+	}{
+		{
+			name:     "unconstrained",
+			template: "{{Bucket}}/{{Year}}/{{Month}}/{{Day}}/{{Name}}.log",
+			input:    "logs/2024/01/02/app-server.log",
+		},
+		{
+			name:     "long",
+			template: "s3://{{Bucket}}/{{Prefix}}/dt={{Date}}/{{Name}}.parquet",
+			input:    "s3://analytics/" + strings.Repeat("events-", 20) + "raw/dt=2024-01-02/part-00001.parquet",
+		},
+		{
+			name:     "typed",
+			template: "{{Service}}-{{Seq:int}}.log",
+			input:    "api-gateway-42.log",
+		},
+		{
+			name:     "optional section",
+			template: "{{Name}}[-{{Suffix}}].log",
+			input:    "app-server.log",
+		},
+		{
+			name:     "most sections mismatch",
+			template: strings.Repeat("[{{A}}-]", maxSections) + "{{B}}.log",
+			input:    "a-a-a-a-a-a-a-a-a-a-a-a-a-a-a-a.txt",
+			wantErr:  ErrTemplateMismatch,
+		},
+	}
+
+	for _, bm := range benchmarks {
+		b.Run(bm.name, func(b *testing.B) {
+			tmpl := MustNew(bm.template, WithOptionalSections([2]string{"[", "]"}))
+			b.ReportAllocs()
+			for b.Loop() {
+				_, err := tmpl.ParseToMap(bm.input)
+				if err != nil && !errors.Is(err, ErrAmbiguousTemplate) && !errors.Is(err, bm.wantErr) {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkParse(b *testing.B) {
+	type Object struct {
+		Bucket string
+		Year   int
+		Month  int
+		Day    int
+		Name   string
+	}
+	tmpl := MustNew("{{Bucket}}/{{Year}}/{{Month}}/{{Day}}/{{Name}}.log")
+	b.ReportAllocs()
+	for b.Loop() {
+		var out Object
+		if err := tmpl.Parse("logs/2024/01/02/app-server.log", &out); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -1,8 +1,12 @@
 package twist
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"iter"
+	"reflect"
+	"time"
 )
 
 var (
@@ -21,13 +25,31 @@ var (
 	// ErrTemplateMismatch is returned when parsing a string which does not match the original template.
 	ErrTemplateMismatch = fmt.Errorf("%w: template mismatch", ErrTwist)
 
+	// ErrOutOfRange is returned when parsing a number which does not fit in the type it is
+	// being stored in. It wraps ErrInvalidData.
+	ErrOutOfRange = fmt.Errorf("%w: value out of range", ErrInvalidData)
+
 	// ErrAmbiguousTemplate is returned when attempting to get a unique data set from a template
 	// which has multiple possible data sets.
 	ErrAmbiguousTemplate = fmt.Errorf("%w: template is ambiguous", ErrTwist)
+
+	// ErrTooManyMatches is returned when a string has more possible data sets than the limit
+	// set with WithMaxMatches. It wraps ErrAmbiguousTemplate.
+	ErrTooManyMatches = fmt.Errorf("%w: too many matches", ErrAmbiguousTemplate)
 )
 
 type twistConfig struct {
-	Delimiters [2]string
+	Delimiters        [2]string
+	SectionDelimiters [2]string
+	LowercaseFields   bool
+	IntegerLiterals   bool
+	NilPlaceholder    *string
+	MaxMatches        int
+	Unambiguous       bool
+	AllErrors         bool
+	Location          *time.Location
+	Codecs            map[reflect.Type]*codec
+	NamedCodecs       map[string]*codec
 }
 
 type twistOption func(*twistConfig) error
@@ -47,10 +69,174 @@ func WithDelimiters(delimiters [2]string) twistOption {
 	}
 }
 
+// When creating a 'twist' with `New` this function enables optional sections, which are
+// surrounded by the given delimiters, e.g. '[' and ']' for '{{ Name }}[-{{ Suffix }}].log'.
+//
+// An optional section must contain at least one field. When executing, the section is only
+// included if one of it's fields is not the zero value, or for maps, is present. When parsing,
+// the section may be left out, in which case it's fields are not included in the result.
+func WithOptionalSections(delimiters [2]string) twistOption {
+	return func(c *twistConfig) error {
+		if delimiters[0] == delimiters[1] {
+			return fmt.Errorf("optional section delimeters must not match: %w", ErrInvalidConfig)
+		}
+		if len(delimiters[0]) < 1 || len(delimiters[1]) < 1 {
+			return fmt.Errorf("optional section delimeters must not be empty: %w", ErrInvalidConfig)
+		}
+		c.SectionDelimiters = delimiters
+		return nil
+	}
+}
+
+// When creating a 'twist' with `New` this function allows field names to start with a
+// lowercase letter, e.g. {{ region }}. This is useful when fields are named using `twist`
+// struct tags or when executing a template with maps.
+func WithLowercaseFields() twistOption {
+	return func(c *twistConfig) error {
+		c.LowercaseFields = true
+		return nil
+	}
+}
+
+// When creating a 'twist' with `New` this function allows integers to be parsed using Go's
+// integer literal syntax, i.e. with a 0x, 0o or 0b base prefix and '_' digit separators, e.g.
+// 0xff or 1_000. Unlike Go, a leading zero doesn't mean octal, so zero padded integers like
+// 0042 are base 10. By default integers must be plain base 10 numbers.
+func WithIntegerLiterals() twistOption {
+	return func(c *twistConfig) error {
+		c.IntegerLiterals = true
+		return nil
+	}
+}
+
+// When creating a 'twist' with `New` this function sets the text used for nil pointers and
+// interfaces when executing, by default they are empty. Fields also accept the placeholder
+// when parsing, whatever their type or constraints, and it is decoded as nil, or the zero
+// value for types that can't be nil. Without a placeholder, nil values in fields with a type,
+// format or pattern are an error when executing, as empty text doesn't satisfy them.
+func WithNilPlaceholder(placeholder string) twistOption {
+	return func(c *twistConfig) error {
+		c.NilPlaceholder = &placeholder
+		return nil
+	}
+}
+
+// When creating a 'twist' with `New` this function limits the number of data sets returned
+// by ParseToMaps and ParseAllAs. The search is stopped and ErrTooManyMatches is returned if a
+// string has more than n possible data sets. By default there is no limit.
+func WithMaxMatches(n int) twistOption {
+	return func(c *twistConfig) error {
+		if n < 1 {
+			return fmt.Errorf("max matches must be at least 1: %w", ErrInvalidConfig)
+		}
+		c.MaxMatches = n
+		return nil
+	}
+}
+
+// When creating a 'twist' with `New` this function causes an error to be returned if the
+// template could be ambiguous when parsing, see Twist.Analyze.
+func WithUnambiguous() twistOption {
+	return func(c *twistConfig) error {
+		c.Unambiguous = true
+		return nil
+	}
+}
+
+// When creating a 'twist' with `New` this function causes Execute and the parse functions to
+// report every field that can't be executed or decoded, joined with errors.Join in the order
+// the fields appear in the template, instead of stopping at the first.
+func WithAllErrors() twistOption {
+	return func(config *twistConfig) error {
+		config.AllErrors = true
+		return nil
+	}
+}
+
+// When creating a 'twist' with `New` this function sets the time zone used by time fields.
+// Times are converted to the location before they are formatted and text is parsed in the
+// location if the field's layout does not include a time zone. By default times are
+// formatted in their own location and parsed as UTC.
+func WithLocation(location *time.Location) twistOption {
+	return func(c *twistConfig) error {
+		if location == nil {
+			return fmt.Errorf("location must not be nil: %w", ErrInvalidConfig)
+		}
+		c.Location = location
+		return nil
+	}
+}
+
+// When creating a 'twist' with `New` this function registers functions that convert values
+// of type T to and from text. These are used instead of the default conversions for every
+// field holding a T, both when executing and parsing.
+func WithCodec[T any](encode func(T) (string, error), decode func(string) (T, error)) twistOption {
+	return func(c *twistConfig) error {
+		registered, err := newCodec("", encode, decode)
+		if err != nil {
+			return err
+		}
+		if c.Codecs == nil {
+			c.Codecs = make(map[reflect.Type]*codec)
+		}
+		c.Codecs[registered.typ] = registered
+		return nil
+	}
+}
+
+// When creating a 'twist' with `New` this function registers functions that convert values
+// of type T to and from text under the given name. A field uses them when the name follows
+// it in the template, e.g. {{ Color|hexcolor }}.
+func WithNamedCodec[T any](name string, encode func(T) (string, error), decode func(string) (T, error)) twistOption {
+	return func(c *twistConfig) error {
+		if valid, _ := isValidSegment(name, true); !valid {
+			return fmt.Errorf("codec name '%s' must be a letter followed by letters, digits, and underscores: %w", name, ErrInvalidConfig)
+		}
+		registered, err := newCodec(name, encode, decode)
+		if err != nil {
+			return err
+		}
+		if c.NamedCodecs == nil {
+			c.NamedCodecs = make(map[string]*codec)
+		}
+		c.NamedCodecs[name] = registered
+		return nil
+	}
+}
+
 // New creates a 'twist' and errors if the template is invald.
 //
 // Twists are reversible templates that can be used to create basic string template
-// using {{ and }} as delimeters by default.
+// using {{ and }} as delimeters by default. A delimiter can be included as literal text
+// by repeating it, e.g. {{{{ is the literal text {{. A field can be used more than once in
+// a template, in which case every occurrence must match the same text when parsing.
+//
+// A field can be annotated with a type, e.g. {{ Age:int }}, in which case it only matches
+// text that is valid for that type when parsing. The supported types are string, int, uint,
+// float, bool, time and duration. A time field can be followed by a layout for time.Format,
+// e.g. {{ When:time "2006-01-02" }}, otherwise time.RFC3339 is used.
+//
+// Alternatively a printf style verb can be given, e.g. {{ Seq:%06d }} or {{ Price:%.2f }},
+// which is used to format the field and to strip any padding when parsing. The supported
+// verbs are d, x, X, o, b, f, F, e, E, g, G, s and t.
+//
+// A field can also be constrained by a regular expression, e.g.
+// {{ Id /[0-9a-f]{8}/ }}, which must match the whole of the field's text both when
+// executing and parsing.
+//
+// A field can be converted to and from text with a codec registered by WithNamedCodec by
+// following it with the codec's name, e.g. {{ Color|hexcolor }}.
+//
+// Fields are looked up in structs by name unless the struct field has a `twist` tag, e.g.
+// `twist:"Region"`, in which case the tag is used as it's name instead. Struct fields with
+// the tag `twist:"-"` are ignored. Fields in nested structs and maps are referred to with a
+// dotted path, e.g. {{ Owner.Team.Name }}, and any nil pointers or maps on the path are
+// allocated when parsing.
+//
+// A field ending in '...', e.g. {{ Tags... }}, is a list which is executed from a slice by
+// joining it's elements with a separator and parsed back into a slice. The separator is ','
+// unless given with sep, e.g. {{ Tags... sep=";" }}. Any type, format or pattern applies to
+// each element of the list.
 func New(s string, opts ...twistOption) (Twist, error) {
 	config := twistConfig{Delimiters: [2]string{"{{", "}}"}}
 	for _, opt := range opts {
@@ -59,15 +245,40 @@ func New(s string, opts ...twistOption) (Twist, error) {
 		}
 	}
 
-	result, err := extractFields(s, config.Delimiters)
+	for _, d := range config.SectionDelimiters {
+		if d != "" && (d == config.Delimiters[0] || d == config.Delimiters[1]) {
+			return Twist{}, fmt.Errorf("optional section delimeters must not match field delimiters: %w", ErrInvalidConfig)
+		}
+	}
+
+	fields, pretext, sections, err := extractFields(s, config)
 	if err != nil {
 		return Twist{}, err
 	}
-	return Twist{
+	t := Twist{
 		original:     s,
-		fieldParts:   result[0],
-		pretextParts: result[1],
-	}, nil
+		fieldParts:   fields,
+		pretextParts: pretext,
+		sections:     sections,
+		codecs:       config.Codecs,
+		maxMatches:   config.MaxMatches,
+		allErrors:    config.AllErrors,
+	}
+	t.matcher = t.compileMatcher()
+	d := t.decoder()
+	t.fieldsByName, t.fieldNames = d.fields, d.order
+
+	if config.Unambiguous {
+		if ambiguities := t.Analyze(); len(ambiguities) > 0 {
+			errs := make([]error, len(ambiguities))
+			for i, ambiguity := range ambiguities {
+				offset := t.fieldsByName[ambiguity.Field].name.start
+				errs[i] = newTemplateError(s, offset, "%s", ambiguity.Reason)
+			}
+			return Twist{}, errors.Join(errs...)
+		}
+	}
+	return t, nil
 }
 
 // MustNew is a convenience function that wraps `New` and panics if the template is invalid.
@@ -109,17 +320,13 @@ func (t Twist) Execute(data any, opts ...executeOption) (string, error) {
 	}
 
 	if config.ForceUnique {
-		ch := t.findFieldIndicies(result)
-		chRes, ok := <-ch
-
-		if !ok || chRes.err != nil {
+		results := t.findUnique(result)
+		if len(results) == 0 || results[0].err != nil {
 			return "", fmt.Errorf("unable to parse resulting string: %w", ErrInvalidData)
 		}
-		chRes, ok = <-ch
-		if ok {
+		if len(results) > 1 {
 			return "", fmt.Errorf("multiple mathces: %w", ErrAmbiguousTemplate)
 		}
-
 	}
 	return result, nil
 }
@@ -139,50 +346,74 @@ func (t Twist) MustExecute(data any, opts ...executeOption) string {
 // If there is not a unique set of data then this function errors. The ParseToMaps function
 // can be used to get all possible data sets.
 func (t Twist) ParseToMap(s string) (map[string]string, error) {
-	resultMap := map[string]string{}
-
-	ch := t.findFieldIndicies(s)
-	result, ok := <-ch
-	if !ok {
-		panic("Unexpected error; channel closed parsing string in ParseToMap")
+	results := t.findUnique(s)
+	if len(results) == 0 {
+		return nil, t.newMismatchError(s, "string does not match template", 0, "", -1)
 	}
-	if result.err != nil {
-		return nil, result.err
+	if results[0].err != nil {
+		return nil, results[0].err
 	}
-	_, ok = <-ch
-	if ok {
+	if len(results) > 1 {
 		return nil, fmt.Errorf("multiple matches: %w", ErrAmbiguousTemplate)
 	}
-
-	for i, field := range t.fields() {
-		resultMap[field] = s[result.val[i][0]:result.val[i][1]]
-	}
-	return resultMap, nil
+	return t.resultMap(s, results[0].val), nil
 }
 
 // ParseToMaps takes a string generated by executing a template and returns all
 // possible data sets could have generatd string from the given template.
 func (t Twist) ParseToMaps(s string) ([]map[string]string, error) {
-	ch := t.findFieldIndicies(s)
+	return t.ParseToMapsContext(context.Background(), s)
+}
+
+// ParseToMapsContext is like ParseToMaps but stops searching for data sets, and returns the
+// context's error, if the context is done first.
+func (t Twist) ParseToMapsContext(ctx context.Context, s string) ([]map[string]string, error) {
 	var resultMaps []map[string]string
-	for result := range ch {
+	for result := range t.findFieldIndiciesContext(ctx, s) {
 		if result.err != nil {
 			return nil, result.err
 		}
-		resultMap := make(map[string]string)
-		for i, field := range t.fields() {
-			resultMap[field] = s[result.val[i][0]:result.val[i][1]]
+		if t.maxMatches > 0 && len(resultMaps) == t.maxMatches {
+			return nil, fmt.Errorf("more than %d matches: %w", t.maxMatches, ErrTooManyMatches)
 		}
-		resultMaps = append(resultMaps, resultMap)
-
+		resultMaps = append(resultMaps, t.resultMap(s, result.val))
 	}
 	return resultMaps, nil
 }
 
+// Matches returns an iterator over every possible data set that could have generated the
+// given string from the template, see ParseToMaps. The data sets are found as they are
+// needed so stopping early avoids searching for the rest. If the string does not match the
+// template the iterator is empty.
+func (t Twist) Matches(s string) iter.Seq[map[string]string] {
+	return func(yield func(map[string]string) bool) {
+		for result := range t.findFieldIndicies(s) {
+			if result.err != nil || !yield(t.resultMap(s, result.val)) {
+				return
+			}
+		}
+	}
+}
+
+// Build the data set for the field indicies of a match, leaving out fields in omitted
+// optional sections
+func (t Twist) resultMap(s string, indicies [][2]int) map[string]string {
+	resultMap := make(map[string]string)
+	for i, field := range t.fieldParts {
+		if indicies[i][0] != -1 {
+			resultMap[field.String()] = field.normalise(s[indicies[i][0]:indicies[i][1]])
+		}
+	}
+	return resultMap
+}
+
 // Parse takes a string generated by executing a template and returns the original data
 // that was used when executing the template.
 //
-// The parsed data is cast to the appropriate type and stored in the provided struct.
+// The parsed data is cast to the appropriate type and stored in the provided struct. Types
+// implementing encoding.TextUnmarshaler are decoded using it. Pointers are allocated as
+// needed and empty text is decoded as nil for pointer, interface and slice fields, see
+// WithNilPlaceholder to use different text for nil values.
 //
 // If the provided string could have been generated using multiple different data sets
 // then this function errors. The ParseToMaps function  can be used to get all possible data
@@ -192,5 +423,44 @@ func (t Twist) Parse(s string, out any) error {
 	if err != nil {
 		return err
 	}
-	return decode(result, out)
+	return t.decoder().decode(result, out)
+}
+
+// ParseAs parses a string generated by executing a template into a new value of type T, which
+// must be a struct or a pointer to a struct. See Parse for details.
+func ParseAs[T any](t Twist, s string) (T, error) {
+	result, err := t.ParseToMap(s)
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+	return decodeAs[T](t.decoder(), result)
+}
+
+// ParseAllAs parses a string generated by executing a template into a value of type T for
+// every possible data set that could have generated the string. T must be a struct or a
+// pointer to a struct. Data sets that can't be decoded into T are left out, and the error
+// for the first of them is returned if none can be. See ParseToMaps and Parse for details.
+func ParseAllAs[T any](t Twist, s string) ([]T, error) {
+	results, err := t.ParseToMaps(s)
+	if err != nil {
+		return nil, err
+	}
+	d := t.decoder()
+	values := make([]T, 0, len(results))
+	var firstErr error
+	for _, result := range results {
+		value, err := decodeAs[T](d, result)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		values = append(values, value)
+	}
+	if len(values) == 0 && firstErr != nil {
+		return nil, firstErr
+	}
+	return values, nil
 }
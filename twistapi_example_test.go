@@ -1,6 +1,9 @@
 package twist
 
-import "fmt"
+import (
+	"errors"
+	"fmt"
+)
 
 func Example() {
 	data := map[string]string{
@@ -47,7 +50,7 @@ func Example_with_structs() {
 func ExampleNew_error() {
 	_, err := New("{{ Greeting }}, {{ Subject!")
 	fmt.Println(err)
-	// Output: unmatched delimiters: twist error: invalid template
+	// Output: line 1, column 17: unmatched delimiters: twist error: invalid template
 }
 
 func ExampleNew_custom_delimeters() {
@@ -102,3 +105,103 @@ func ExampleTwist_ParseToMaps() {
 	// map[Greeting:Good Night Subject:Mr. Tom]
 	// map[Greeting:Good Night Mr. Subject:Tom]
 }
+
+func ExampleTwist_Parse_format_verbs() {
+	type Backup struct {
+		Name string
+		Seq  int
+	}
+	twist := MustNew("{{ Name }}-{{ Seq:%06d }}.tar")
+	message := twist.MustExecute(Backup{Name: "db", Seq: 42})
+	fmt.Printf("%#v\n", message)
+
+	var output Backup
+	twist.Parse(message, &output)
+	fmt.Printf("%#v\n", output)
+
+	// Output:
+	// "db-000042.tar"
+	// twist.Backup{Name:"db", Seq:42}
+}
+
+func ExampleWithOptionalSections() {
+	twist := MustNew("{{ Name }}[.{{ Rotation:int }}].log", WithOptionalSections([2]string{"[", "]"}))
+	fmt.Println(twist.MustExecute(map[string]any{"Name": "app", "Rotation": 3}))
+	fmt.Println(twist.MustExecute(map[string]any{"Name": "app"}))
+
+	fields, _ := twist.ParseToMap("app.log")
+	fmt.Printf("%#v\n", fields)
+
+	// Output:
+	// app.3.log
+	// app.log
+	// map[string]string{"Name":"app"}
+}
+
+func Example_nested_structs() {
+	type Source struct {
+		Bucket string
+		Path   string
+	}
+	type Job struct {
+		Name   string
+		Source *Source
+	}
+
+	twist := MustNew("{{ Name }}: s3://{{ Source.Bucket }}/{{ Source.Path }}")
+	message := twist.MustExecute(Job{Name: "sync", Source: &Source{Bucket: "logs", Path: "2024-01.gz"}})
+	fmt.Printf("%#v\n", message)
+
+	var output Job
+	twist.Parse(message, &output)
+	fmt.Printf("%#v\n", *output.Source)
+
+	// Output:
+	// "sync: s3://logs/2024-01.gz"
+	// twist.Source{Bucket:"logs", Path:"2024-01.gz"}
+}
+
+func ExampleParseAllAs() {
+	type Release struct {
+		App     string
+		Version string
+	}
+	twist := MustNew("{{ App }}-{{ Version }}.tar.gz")
+
+	releases, _ := ParseAllAs[Release](twist, "web-api-1.2.tar.gz")
+	for _, release := range releases {
+		fmt.Printf("%#v\n", release)
+	}
+
+	// Output:
+	// twist.Release{App:"web", Version:"api-1.2"}
+	// twist.Release{App:"web-api", Version:"1.2"}
+}
+
+func ExampleTwist_Matches() {
+	twist := MustNew("{{ Bucket }}/{{ Key }}")
+	for match := range twist.Matches("logs/2024/app.log") {
+		fmt.Printf("%#v\n", match)
+	}
+
+	// Output:
+	// map[string]string{"Bucket":"logs", "Key":"2024/app.log"}
+	// map[string]string{"Bucket":"logs/2024", "Key":"app.log"}
+}
+
+func ExampleMismatchError_Render() {
+	twist := MustNew("{{ Service }}-{{ Seq:int }}.log")
+	_, err := twist.ParseToMap("api-1O.log")
+
+	var mismatch *MismatchError
+	if errors.As(err, &mismatch) {
+		fmt.Println(mismatch.Render())
+	}
+
+	// Output:
+	// input:    api-1O.log
+	//               ^
+	// template: {{ Service }}-{{ Seq:int }}.log
+	//                            ^
+	// field 'Seq' does not match
+}
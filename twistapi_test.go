@@ -1,10 +1,13 @@
 package twist
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"net/netip"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/google/go-cmp/cmp"
 )
@@ -222,6 +225,54 @@ func TestNewSuccess(t *testing.T) {
 			expectedFields:  []string{"Hello", "Hello", "Hello"},
 			expectedPretext: []string{"", " ", " - ", ""},
 		},
+		{
+			name:            "typed fields",
+			template:        "{{ Name:string }}-{{Age:int}}-{{ Ratio : float }}-{{ Active:bool }}",
+			expectedFields:  []string{"Name", "Age", "Ratio", "Active"},
+			expectedPretext: []string{"", "-", "-", "-", ""},
+		},
+		{
+			name:            "pattern",
+			template:        "{{ Id /[0-9a-f]{8}/ }}/{{Path:string /a\\/b|c/}}",
+			expectedFields:  []string{"Id", "Path"},
+			expectedPretext: []string{"", "/", ""},
+		},
+		{
+			name:            "time",
+			template:        "{{ When:time \"2006-01-02 15:04\" }}/{{Then:time\"Jan _2\"}}/{{Now:time}}/{{ Timeout:duration }}",
+			expectedFields:  []string{"When", "Then", "Now", "Timeout"},
+			expectedPretext: []string{"", "/", "/", "/", ""},
+		},
+		{
+			name:            "nested",
+			template:        "{{ Owner.Team.Name }}",
+			expectedFields:  []string{"Owner.Team.Name"},
+			expectedPretext: []string{"", ""},
+		},
+		{
+			name:            "lists",
+			template:        "{{ Tags... }}/{{ Ids...:int sep=\";\" }}/{{Paths... /[a-z]+/ sep=\"\\\\\"}}",
+			expectedFields:  []string{"Tags", "Ids", "Paths"},
+			expectedPretext: []string{"", "/", "/", ""},
+		},
+		{
+			name:            "escaped delimiters",
+			template:        "{{{{ literal }}}} {{ Name }}",
+			expectedFields:  []string{"Name"},
+			expectedPretext: []string{"{{ literal }} ", ""},
+		},
+		{
+			name:            "escaped delimiters around field",
+			template:        "{{{{{{Name}}}}}}",
+			expectedFields:  []string{"Name"},
+			expectedPretext: []string{"{{", "}}"},
+		},
+		{
+			name:            "escaped delimiters only",
+			template:        "a}}}}b{{{{c",
+			expectedFields:  []string{},
+			expectedPretext: []string{"a}}b{{c"},
+		},
 	}
 
 	for _, tt := range tests {
@@ -288,6 +339,24 @@ func TestNewError(t *testing.T) {
 			errorType: ErrInvalidTemplate,
 			errorMsg:  "field must not be empty",
 		},
+		{
+			name:      "empty path segment",
+			template:  "{{Owner..Name}}",
+			errorType: ErrInvalidTemplate,
+			errorMsg:  "field must not contain empty path segments",
+		},
+		{
+			name:      "trailing path segment",
+			template:  "{{Owner.}}",
+			errorType: ErrInvalidTemplate,
+			errorMsg:  "field must not contain empty path segments",
+		},
+		{
+			name:      "lowercase path segment",
+			template:  "{{Owner.name}}",
+			errorType: ErrInvalidTemplate,
+			errorMsg:  "field must start with an uppercase letter",
+		},
 		{
 			name:      "missing closing brace 1",
 			template:  "{{ Hello",
@@ -330,6 +399,90 @@ func TestNewError(t *testing.T) {
 			errorType: ErrInvalidTemplate,
 			errorMsg:  "nested delimiters",
 		},
+		{
+			name:      "escaped open delimiter",
+			template:  "{{{{ Hello }}",
+			errorType: ErrInvalidTemplate,
+			errorMsg:  "unmatched delimiters",
+		},
+		{
+			name:      "escaped close delimiter",
+			template:  "{{ Hello }}}}",
+			errorType: ErrInvalidTemplate,
+			errorMsg:  "unmatched delimiters",
+		},
+		{
+			name:      "unknown type",
+			template:  "{{ Hello:complex }}",
+			errorType: ErrInvalidTemplate,
+			errorMsg:  "field 'Hello' has unknown type 'complex'",
+		},
+		{
+			name:      "empty type",
+			template:  "{{ Hello: }}",
+			errorType: ErrInvalidTemplate,
+			errorMsg:  "field 'Hello' has unknown type ''",
+		},
+		{
+			name:      "text after type",
+			template:  "{{ Hello:int World }}",
+			errorType: ErrInvalidTemplate,
+			errorMsg:  "field 'Hello' has unexpected text 'World'",
+		},
+		{
+			name:      "unterminated pattern",
+			template:  "{{ Hello /[a-z]+ }}",
+			errorType: ErrInvalidTemplate,
+			errorMsg:  "field 'Hello' has an unterminated pattern",
+		},
+		{
+			name:      "invalid layout",
+			template:  "{{ When:time \"2006 }}",
+			errorType: ErrInvalidTemplate,
+			errorMsg:  "field 'When' has an invalid layout",
+		},
+		{
+			name:      "layout but not a time",
+			template:  "{{ When:int \"2006\" }}",
+			errorType: ErrInvalidTemplate,
+			errorMsg:  "field 'When' has unexpected text '\"2006\"'",
+		},
+		{
+			name:      "separator but not a list",
+			template:  "{{ Hello sep=\",\" }}",
+			errorType: ErrInvalidTemplate,
+			errorMsg:  "field 'Hello' has a separator but is not a list",
+		},
+		{
+			name:      "empty separator",
+			template:  "{{ Hello... sep=\"\" }}",
+			errorType: ErrInvalidTemplate,
+			errorMsg:  "field 'Hello' has an empty separator",
+		},
+		{
+			name:      "unquoted separator",
+			template:  "{{ Hello... sep=, }}",
+			errorType: ErrInvalidTemplate,
+			errorMsg:  "field 'Hello' has an invalid separator",
+		},
+		{
+			name:      "unsupported format",
+			template:  "{{ Hello:%q }}",
+			errorType: ErrInvalidTemplate,
+			errorMsg:  "field 'Hello' has unsupported format '%q'",
+		},
+		{
+			name:      "invalid pattern",
+			template:  "{{ Hello /[a-z/ }}",
+			errorType: ErrInvalidTemplate,
+			errorMsg:  "field 'Hello' has an invalid pattern '[a-z'",
+		},
+		{
+			name:      "text after pattern",
+			template:  "{{ Hello /[a-z]/ World }}",
+			errorType: ErrInvalidTemplate,
+			errorMsg:  "field 'Hello' has unexpected text 'World'",
+		},
 	}
 
 	for _, tt := range tests {
@@ -394,6 +547,91 @@ func TestParseToMapSuccess(t *testing.T) {
 				"Hello": "Hi",
 			},
 		},
+		{
+			name:     "typed fields",
+			template: "{{A:int}}-{{B:int}}",
+			result:   "-1-2",
+			want: map[string]string{
+				"A": "-1",
+				"B": "2",
+			},
+		},
+		{
+			name:     "typed field disambiguates",
+			template: "{{Name}}-{{Age:uint}}-{{Active:bool}}",
+			result:   "Mary-Jane-42-true",
+			want: map[string]string{
+				"Name":   "Mary-Jane",
+				"Age":    "42",
+				"Active": "true",
+			},
+		},
+		{
+			name:     "pattern disambiguates",
+			template: "{{Name}}-{{Id /[0-9a-f]{8}/}}-{{Suffix}}",
+			result:   "my-object-0badf00d-final-v2",
+			want: map[string]string{
+				"Name":   "my-object",
+				"Id":     "0badf00d",
+				"Suffix": "final-v2",
+			},
+		},
+		{
+			name:     "time disambiguates",
+			template: "{{Name}}-{{When:time \"2006-01-02\"}}-{{Suffix}}",
+			result:   "a-b-2024-01-31-c-d",
+			want: map[string]string{
+				"Name":   "a-b",
+				"When":   "2024-01-31",
+				"Suffix": "c-d",
+			},
+		},
+		{
+			name:     "typed list",
+			template: "{{Ids...:int}}-{{Name}}",
+			result:   "1,-2,3-a-b",
+			want: map[string]string{
+				"Ids":  "1,-2,3",
+				"Name": "a-b",
+			},
+		},
+		{
+			name:     "formatted list",
+			template: "{{Ids...:%03d sep=\" \"}}",
+			result:   "001 -02 100",
+			want: map[string]string{
+				"Ids": "1 -2 100",
+			},
+		},
+		{
+			name:     "escaped delimiters",
+			template: "{{{{{{Name}}}}}}: {{{{ {{Value}} }}}}",
+			result:   "{{Key}}: {{ Hello }}",
+			want: map[string]string{
+				"Name":  "Key",
+				"Value": "Hello",
+			},
+		},
+		{
+			name:     "format verbs",
+			template: "{{Seq:%06d}}-{{Hex:%04X}}-{{Price:%8.2f}}-{{Name:%5s}}",
+			result:   "-00042-00FF-    3.14-   ab",
+			want: map[string]string{
+				"Seq":   "-42",
+				"Hex":   "255",
+				"Price": "3.14",
+				"Name":  "ab",
+			},
+		},
+		{
+			name:     "repeated fields disambiguate",
+			template: "{{Env}}-{{Service}}-{{Env}}",
+			result:   "prod-a-b-prod",
+			want: map[string]string{
+				"Env":     "prod",
+				"Service": "a-b",
+			},
+		},
 		{
 			name:     "complex",
 			template: " {{A}} {{B}} {{A}}  {{C}} {{B}} {{G}} ",
@@ -457,6 +695,27 @@ func TestParseToMapError(t *testing.T) {
 			errorType: ErrTemplateMismatch,
 			errorMsg:  "strings do not match",
 		},
+		{
+			name:      "wrong type",
+			template:  "{{A:int}}-{{B:float}}",
+			result:    "1-2-x",
+			errorType: ErrTemplateMismatch,
+			errorMsg:  "string does not match template",
+		},
+		{
+			name:      "format mismatch",
+			template:  "{{A:%06d}}",
+			result:    "0042",
+			errorType: ErrTemplateMismatch,
+			errorMsg:  "string does not match template",
+		},
+		{
+			name:      "pattern mismatch",
+			template:  "{{A /[a-z]+/}}",
+			result:    "abc1",
+			errorType: ErrTemplateMismatch,
+			errorMsg:  "string does not match template",
+		},
 	}
 
 	for _, tt := range tests {
@@ -581,6 +840,621 @@ func TestParseToMapsError(t *testing.T) {
 	}
 }
 
+func TestTimeFields(t *testing.T) {
+	type Backup struct {
+		Name    string
+		When    time.Time
+		Timeout time.Duration
+	}
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skipf("time zone database not available: %v", err)
+	}
+
+	type testCase struct {
+		name     string
+		template string
+		opts     []twistOption
+		data     Backup
+		want     string
+		wantData Backup
+	}
+
+	when := time.Date(2024, 3, 1, 23, 30, 0, 0, time.UTC)
+	tests := []testCase{
+		{
+			name:     "default layout",
+			template: "{{Name}}-{{When:time}}",
+			data:     Backup{Name: "db", When: when},
+			want:     "db-2024-03-01T23:30:00Z",
+			wantData: Backup{Name: "db", When: when},
+		},
+		{
+			name:     "custom layout",
+			template: "{{Name}}-{{When:time \"2006-01-02T150405\"}}.tar",
+			data:     Backup{Name: "db-main", When: when},
+			want:     "db-main-2024-03-01T233000.tar",
+			wantData: Backup{Name: "db-main", When: when},
+		},
+		{
+			name:     "location",
+			template: "{{Name}}/{{When:time \"2006/01/02/15\"}}",
+			opts:     []twistOption{WithLocation(newYork)},
+			data:     Backup{Name: "db", When: when},
+			want:     "db/2024/03/01/18",
+			wantData: Backup{Name: "db", When: time.Date(2024, 3, 1, 18, 0, 0, 0, newYork)},
+		},
+		{
+			name:     "duration",
+			template: "{{Name}}-{{Timeout:duration}}",
+			data:     Backup{Name: "db", Timeout: 90 * time.Second},
+			want:     "db-1m30s",
+			wantData: Backup{Name: "db", Timeout: 90 * time.Second},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpl, err := New(tt.template, tt.opts...)
+			if err != nil {
+				t.Errorf("New() error = %v", err)
+				return
+			}
+			got, err := tmpl.Execute(tt.data)
+			if err != nil {
+				t.Errorf("Execute() error = %v", err)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("Execute() = %v, want %v", got, tt.want)
+				return
+			}
+
+			var out Backup
+			if err := tmpl.Parse(got, &out); err != nil {
+				t.Errorf("Parse() error = %v", err)
+				return
+			}
+			if diff := cmp.Diff(out, tt.wantData); diff != "" {
+				t.Errorf("Parse() mismatch (-got +want)\n%s", diff)
+			}
+		})
+	}
+
+	_, err = New("{{When:time}}", WithLocation(nil))
+	if !errors.Is(err, ErrInvalidConfig) {
+		t.Errorf("New() error = %v, want %v", err, ErrInvalidConfig)
+	}
+}
+
+func TestStructTags(t *testing.T) {
+	type Object struct {
+		AwsRegion string `twist:"region"`
+		Bucket    string
+		Internal  string `twist:"-"`
+	}
+	data := Object{AwsRegion: "eu-west-1", Bucket: "logs", Internal: "secret"}
+
+	tmpl, err := New("s3://{{ Bucket }}/{{ region }}", WithLowercaseFields())
+	if err != nil {
+		t.Errorf("New() error = %v", err)
+		return
+	}
+	got, err := tmpl.Execute(data)
+	if err != nil {
+		t.Errorf("Execute() error = %v", err)
+		return
+	}
+	if want := "s3://logs/eu-west-1"; got != want {
+		t.Errorf("Execute() = %v, want %v", got, want)
+		return
+	}
+
+	var out Object
+	if err := tmpl.Parse(got, &out); err != nil {
+		t.Errorf("Parse() error = %v", err)
+		return
+	}
+	if diff := cmp.Diff(out, Object{AwsRegion: "eu-west-1", Bucket: "logs"}); diff != "" {
+		t.Errorf("Parse() mismatch (-got +want)\n%s", diff)
+	}
+
+	_, err = MustNew("{{ Internal }}").Execute(data)
+	if !errors.Is(err, ErrInvalidData) || !strings.Contains(err.Error(), "field 'Internal' is missing") {
+		t.Errorf("Execute() error = %v, want field 'Internal' is missing", err)
+	}
+	err = MustNew("{{ Internal }}").Parse("secret", &out)
+	if !errors.Is(err, ErrInvalidData) || !strings.Contains(err.Error(), "field 'Internal' is missing") {
+		t.Errorf("Parse() error = %v, want field 'Internal' is missing", err)
+	}
+}
+
+func TestIntegerLiterals(t *testing.T) {
+	type Flags struct {
+		Mask  uint8
+		Perm  uint16
+		Count int
+		Ids   []int64
+	}
+
+	tmpl, err := New("{{Mask:uint}}-{{Perm}}-{{Count:int}}-{{Ids...:int}}", WithIntegerLiterals())
+	if err != nil {
+		t.Errorf("New() error = %v", err)
+		return
+	}
+	var out Flags
+	if err := tmpl.Parse("0b1010-0o755-1_000-0x1F,-0x2", &out); err != nil {
+		t.Errorf("Parse() error = %v", err)
+		return
+	}
+	want := Flags{Mask: 10, Perm: 0o755, Count: 1000, Ids: []int64{31, -2}}
+	if diff := cmp.Diff(out, want); diff != "" {
+		t.Errorf("Parse() mismatch (-got +want)\n%s", diff)
+	}
+
+	err = tmpl.Parse("0x100-0-0-0", &out)
+	if !errors.Is(err, ErrOutOfRange) || !strings.Contains(err.Error(), "field 'Mask' is out of range for 'uint8'") {
+		t.Errorf("Parse() error = %v, want %v", err, ErrOutOfRange)
+	}
+
+	// Leading zeros don't make an integer octal
+	tmpl = MustNew("{{Name}}-{{Seq}}", WithIntegerLiterals())
+	for input, want := range map[string]int{"file-0042": 42, "file-0089": 89, "file-0_100": 100} {
+		var file struct {
+			Name string
+			Seq  int
+		}
+		if err := tmpl.Parse(input, &file); err != nil || file.Seq != want {
+			t.Errorf("Parse(%q) = %v, %v, want Seq %v", input, file.Seq, err, want)
+		}
+	}
+
+	// Separators must be between digits
+	_, err = MustNew("{{Count:int}}", WithIntegerLiterals()).ParseToMap("1__0")
+	if !errors.Is(err, ErrTemplateMismatch) {
+		t.Errorf("ParseToMap() error = %v, want %v", err, ErrTemplateMismatch)
+	}
+
+	// Typed fields only match base 10 integers by default
+	_, err = MustNew("{{Count:int}}").ParseToMap("0x10")
+	if !errors.Is(err, ErrTemplateMismatch) {
+		t.Errorf("ParseToMap() error = %v, want %v", err, ErrTemplateMismatch)
+	}
+}
+
+func TestNilValues(t *testing.T) {
+	type Record struct {
+		Name  string
+		Age   *int
+		Score *float64
+		Note  *string
+		Ids   []*int
+	}
+	age := 42
+	note := "ok"
+
+	type testCase struct {
+		name     string
+		template string
+		opts     []twistOption
+		data     Record
+		want     string
+		wantData Record
+	}
+
+	tests := []testCase{
+		{
+			name:     "empty",
+			template: "{{Name}}/{{Age}}/{{Score}}/-{{Note}}/{{Ids...:int}}",
+			data:     Record{Name: "a", Age: &age},
+			want:     "a/42//-/",
+			wantData: Record{Name: "a", Age: &age},
+		},
+		{
+			name:     "typed placeholder",
+			template: "x{{Age:int}}-{{Name}}",
+			opts:     []twistOption{WithNilPlaceholder("null")},
+			data:     Record{Name: "a"},
+			want:     "xnull-a",
+			wantData: Record{Name: "a"},
+		},
+		{
+			name:     "format and pattern placeholder",
+			template: "{{Name}}/{{Age:%03d}}/{{Score /[0-9.]+/}}",
+			opts:     []twistOption{WithNilPlaceholder("none")},
+			data:     Record{Name: "a"},
+			want:     "a/none/none",
+			wantData: Record{Name: "a"},
+		},
+		{
+			name:     "placeholder",
+			template: "{{Name}}/{{Age}}/{{Score:%.1f}}/-{{Note}}/{{Ids...:int}}",
+			opts:     []twistOption{WithNilPlaceholder("null")},
+			data:     Record{Name: "a", Note: &note, Ids: []*int{&age, nil}},
+			want:     "a/null/null/-ok/42,null",
+			wantData: Record{Name: "a", Note: &note, Ids: []*int{&age, nil}},
+		},
+		{
+			name:     "nil slice",
+			template: "{{Name}}/{{Age}}/{{Score:%.1f}}/-{{Note}}/{{Ids...:int}}",
+			opts:     []twistOption{WithNilPlaceholder("null")},
+			data:     Record{Name: "a", Age: &age, Score: new(float64)},
+			want:     "a/42/0.0/-null/",
+			wantData: Record{Name: "a", Age: &age, Score: new(float64)},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpl, err := New(tt.template, tt.opts...)
+			if err != nil {
+				t.Errorf("New() error = %v", err)
+				return
+			}
+			got, err := tmpl.Execute(tt.data)
+			if err != nil {
+				t.Errorf("Execute() error = %v", err)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("Execute() = %v, want %v", got, tt.want)
+				return
+			}
+
+			var out Record
+			if err := tmpl.Parse(got, &out); err != nil {
+				t.Errorf("Parse() error = %v", err)
+				return
+			}
+			if diff := cmp.Diff(out, tt.wantData); diff != "" {
+				t.Errorf("Parse() mismatch (-got +want)\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestSliceWithoutList(t *testing.T) {
+	type data struct{ Ids []int }
+	tmpl := MustNew("ids={{Ids}}")
+
+	// A slice is only joined and split for list fields, see {{Ids...}}
+	_, err := tmpl.Execute(data{Ids: []int{1, 2}})
+	if !errors.Is(err, ErrInvalidData) || !strings.Contains(err.Error(), "field 'Ids' is not stringable") {
+		t.Errorf("Execute() error = %v, want field 'Ids' is not stringable", err)
+	}
+	var out data
+	err = tmpl.Parse("ids=1,2", &out)
+	if !errors.Is(err, ErrInvalidData) || !strings.Contains(err.Error(), "field 'Ids' is not a supported type") {
+		t.Errorf("Parse() error = %v, want field 'Ids' is not a supported type", err)
+	}
+}
+
+func TestNestedNilPointers(t *testing.T) {
+	type data struct {
+		A **int
+		B string
+	}
+	tmpl := MustNew("{{A}}-{{B}}")
+
+	var inner *int
+	got, err := tmpl.Execute(data{A: &inner, B: "b"})
+	if err != nil || got != "-b" {
+		t.Errorf("Execute() = %v, %v, want -b", got, err)
+	}
+
+	age := 42
+	inner = &age
+	got, err = tmpl.Execute(data{A: &inner, B: "b"})
+	if err != nil || got != "42-b" {
+		t.Errorf("Execute() = %v, %v, want 42-b", got, err)
+	}
+}
+
+func TestMatches(t *testing.T) {
+	tmpl := MustNew("{{A}}-{{B}}-{{C}}")
+
+	var got []map[string]string
+	for match := range tmpl.Matches("a-b-c-d") {
+		got = append(got, match)
+	}
+	want := []map[string]string{
+		{"A": "a", "B": "b", "C": "c-d"},
+		{"A": "a", "B": "b-c", "C": "d"},
+		{"A": "a-b", "B": "c", "C": "d"},
+	}
+	if diff := cmp.Diff(got, want); diff != "" {
+		t.Errorf("Matches() mismatch (-got +want)\n%s", diff)
+	}
+
+	// Stopping early must not search for the remaining matches
+	count := 0
+	for range tmpl.Matches(strings.Repeat("-", 1000)) {
+		count++
+		if count == 2 {
+			break
+		}
+	}
+	if count != 2 {
+		t.Errorf("Matches() count = %v, want 2", count)
+	}
+
+	for match := range tmpl.Matches("a-b") {
+		t.Errorf("Matches() = %v, want no matches", match)
+	}
+}
+
+func TestMaxMatches(t *testing.T) {
+	type testCase struct {
+		name      string
+		max       int
+		want      int
+		errorType error
+		errorMsg  string
+	}
+
+	tests := []testCase{
+		{name: "under limit", max: 4, want: 3},
+		{name: "at limit", max: 3, want: 3},
+		{name: "over limit", max: 2, errorType: ErrTooManyMatches, errorMsg: "more than 2 matches"},
+		{name: "invalid limit", max: 0, errorType: ErrInvalidConfig, errorMsg: "max matches must be at least 1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpl, err := New("{{A}}-{{B}}-{{C}}", WithMaxMatches(tt.max))
+			var got []map[string]string
+			if err == nil {
+				got, err = tmpl.ParseToMaps("a-b-c-d")
+			}
+			if tt.errorType == nil {
+				if err != nil {
+					t.Errorf("ParseToMaps() error = %v", err)
+				} else if len(got) != tt.want {
+					t.Errorf("ParseToMaps() = %v matches, want %v", len(got), tt.want)
+				}
+				return
+			}
+			if !errors.Is(err, tt.errorType) {
+				t.Errorf("ParseToMaps() error type = '%v', want type '%v'", err, tt.errorType)
+				return
+			}
+			if !strings.Contains(err.Error(), tt.errorMsg) {
+				t.Errorf("ParseToMaps() error = '%v', want to contain '%v'", err, tt.errorMsg)
+			}
+		})
+	}
+
+	// Too many matches is a kind of ambiguity
+	_, err := ParseAllAs[struct{ A, B, C string }](MustNew("{{A}}-{{B}}-{{C}}", WithMaxMatches(1)), "a-b-c-d")
+	if !errors.Is(err, ErrAmbiguousTemplate) {
+		t.Errorf("ParseAllAs() error = %v, want %v", err, ErrAmbiguousTemplate)
+	}
+}
+
+func TestParseToMapsContext(t *testing.T) {
+	tmpl := MustNew("{{A}}-{{B}}")
+	got, err := tmpl.ParseToMapsContext(context.Background(), "a-b-c")
+	if err != nil || len(got) != 2 {
+		t.Errorf("ParseToMapsContext() = %v, %v, want 2 matches", got, err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = tmpl.ParseToMapsContext(ctx, "a-b-c")
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("ParseToMapsContext() error = %v, want %v", err, context.Canceled)
+	}
+
+	// Every way of splitting the string is tried and rejected, which takes far longer than
+	// the timeout
+	tmpl = MustNew("{{A}}{{B}}{{C}}{{D}}{{E:int}}")
+	ctx, cancel = context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err = tmpl.ParseToMapsContext(ctx, strings.Repeat("a", 500))
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("ParseToMapsContext() error = %v, want %v", err, context.DeadlineExceeded)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("ParseToMapsContext() took %v after the deadline", elapsed)
+	}
+}
+
+func TestParseAs(t *testing.T) {
+	type File struct {
+		Name string
+		Part int
+	}
+	tmpl := MustNew("{{Name}}-{{Part}}.log")
+
+	got, err := ParseAs[File](tmpl, "server-3.log")
+	if err != nil {
+		t.Errorf("ParseAs() error = %v", err)
+		return
+	}
+	if diff := cmp.Diff(got, File{Name: "server", Part: 3}); diff != "" {
+		t.Errorf("ParseAs() mismatch (-got +want)\n%s", diff)
+	}
+
+	gotPtr, err := ParseAs[*File](tmpl, "server-3.log")
+	if err != nil {
+		t.Errorf("ParseAs() error = %v", err)
+		return
+	}
+	if diff := cmp.Diff(gotPtr, &File{Name: "server", Part: 3}); diff != "" {
+		t.Errorf("ParseAs() mismatch (-got +want)\n%s", diff)
+	}
+
+	_, err = ParseAs[File](tmpl, "web-server-3-4.log")
+	if !errors.Is(err, ErrAmbiguousTemplate) {
+		t.Errorf("ParseAs() error = %v, want %v", err, ErrAmbiguousTemplate)
+	}
+	_, err = ParseAs[File](tmpl, "server.txt")
+	if !errors.Is(err, ErrTemplateMismatch) {
+		t.Errorf("ParseAs() error = %v, want %v", err, ErrTemplateMismatch)
+	}
+	_, err = ParseAs[string](tmpl, "server-3.log")
+	if !errors.Is(err, ErrInvalidData) {
+		t.Errorf("ParseAs() error = %v, want %v", err, ErrInvalidData)
+	}
+}
+
+func TestParseAllAs(t *testing.T) {
+	type File struct {
+		Name string
+		Part string
+	}
+	tmpl := MustNew("{{Name}}-{{Part}}.log")
+
+	got, err := ParseAllAs[File](tmpl, "web-server-3.log")
+	if err != nil {
+		t.Errorf("ParseAllAs() error = %v", err)
+		return
+	}
+	want := []File{{Name: "web", Part: "server-3"}, {Name: "web-server", Part: "3"}}
+	if diff := cmp.Diff(got, want); diff != "" {
+		t.Errorf("ParseAllAs() mismatch (-got +want)\n%s", diff)
+	}
+
+	_, err = ParseAllAs[File](tmpl, "server.txt")
+	if !errors.Is(err, ErrTemplateMismatch) {
+		t.Errorf("ParseAllAs() error = %v, want %v", err, ErrTemplateMismatch)
+	}
+
+	// Data sets that can't be decoded are left out
+	type Numbered struct {
+		Name string
+		Part int
+	}
+	numbered, err := ParseAllAs[Numbered](tmpl, "web-server-3.log")
+	if err != nil {
+		t.Errorf("ParseAllAs() error = %v", err)
+	} else if diff := cmp.Diff(numbered, []Numbered{{Name: "web-server", Part: 3}}); diff != "" {
+		t.Errorf("ParseAllAs() mismatch (-got +want)\n%s", diff)
+	}
+
+	// It's an error if none of them can be decoded
+	_, err = ParseAllAs[Numbered](tmpl, "web-server-x.log")
+	if !errors.Is(err, ErrInvalidData) || !strings.Contains(err.Error(), "field 'Part' cannot be converted to supplied type") {
+		t.Errorf("ParseAllAs() error = %v, want %v", err, ErrInvalidData)
+	}
+}
+
+func TestAllErrors(t *testing.T) {
+	type data struct {
+		Name  string
+		Age   int8
+		Score float64
+		Tags  []string
+	}
+
+	// The fields named by each error in a joined error
+	fieldsOf := func(err error) []string {
+		var fields []string
+		for _, e := range err.(interface{ Unwrap() []error }).Unwrap() {
+			var fieldErr *FieldError
+			if errors.As(e, &fieldErr) {
+				fields = append(fields, fieldErr.Field)
+			}
+		}
+		return fields
+	}
+
+	type testCase struct {
+		name string
+		run  func(tmpl Twist) error
+		want []string
+	}
+
+	tests := []testCase{
+		{
+			name: "execute struct",
+			run: func(tmpl Twist) error {
+				_, err := tmpl.Execute(struct {
+					Name string
+					Tags []string
+				}{Name: "A", Tags: []string{"a,b"}})
+				return err
+			},
+			want: []string{"Name", "Age", "Score", "Tags"},
+		},
+		{
+			name: "execute map",
+			run: func(tmpl Twist) error {
+				_, err := tmpl.Execute(map[string]any{"Name": "a", "Age": 3, "Tags": "x"})
+				return err
+			},
+			want: []string{"Score"},
+		},
+		{
+			name: "parse",
+			run: func(tmpl Twist) error {
+				var out data
+				return tmpl.Parse("a_300_x_y", &out)
+			},
+			want: []string{"Age", "Score"},
+		},
+		{
+			name: "parse as",
+			run: func(tmpl Twist) error {
+				_, err := ParseAs[data](tmpl, "a_1_x_y")
+				return err
+			},
+			want: []string{"Score"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpl := MustNew("{{Name /[a-z]+/}}_{{Age}}_{{Score}}_{{Tags...}}", WithAllErrors())
+			err := tt.run(tmpl)
+			if !errors.Is(err, ErrInvalidData) {
+				t.Errorf("error type = '%v', want type '%v'", err, ErrInvalidData)
+				return
+			}
+			if diff := cmp.Diff(fieldsOf(err), tt.want); diff != "" {
+				t.Errorf("error fields mismatch (-got +want)\n%s", diff)
+			}
+		})
+	}
+
+	// Without the option the first field in the template is reported, whatever the map order
+	tmpl := MustNew("{{A}}-{{B}}-{{C}}-{{D}}")
+	for range 20 {
+		var out struct{ A, B, C, D int }
+		err := tmpl.Parse("1-x-y-z", &out)
+		var fieldErr *FieldError
+		if !errors.As(err, &fieldErr) || fieldErr.Field != "B" {
+			t.Fatalf("Parse() error = %v, want an error for field 'B'", err)
+		}
+	}
+}
+
+func TestLowercaseFieldsError(t *testing.T) {
+	tests := []struct {
+		name     string
+		template string
+		errorMsg string
+	}{
+		{name: "starts with number", template: "{{ 1field }}", errorMsg: "field must start with a letter"},
+		{name: "starts with underscore", template: "{{ _field }}", errorMsg: "field must start with a letter"},
+		{name: "special characters", template: "{{ field-name }}", errorMsg: "field must contain only letters, digits, and underscores"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := New(tt.template, WithLowercaseFields())
+			if !errors.Is(err, ErrInvalidTemplate) {
+				t.Errorf("New() error type = '%v', want type '%v'", err, ErrInvalidTemplate)
+				return
+			}
+			if !strings.Contains(err.Error(), tt.errorMsg) {
+				t.Errorf("New() error = '%v', want to contain '%v'", err, tt.errorMsg)
+			}
+		})
+	}
+}
+
 func TestParse(t *testing.T) {
 	type testCase struct {
 		name     string
@@ -604,6 +1478,57 @@ func TestParse(t *testing.T) {
 				Name: "World",
 			},
 		},
+		{
+			name:     "lists",
+			template: "{{Name}}[{{Tags... sep=\"|\"}}]({{Ids...:uint}})",
+			result:   "job[a|b c](1,2)",
+			out: &struct {
+				Name string
+				Tags []string
+				Ids  []uint16
+			}{},
+			want: &struct {
+				Name string
+				Tags []string
+				Ids  []uint16
+			}{
+				Name: "job",
+				Tags: []string{"a", "b c"},
+				Ids:  []uint16{1, 2},
+			},
+		},
+		{
+			name:     "text unmarshaler",
+			template: "{{Color}}@{{Addr}}",
+			result:   "#0a0b0c@10.1.2.3",
+			out: &struct {
+				Color *hexColor
+				Addr  netip.Addr
+			}{},
+			want: &struct {
+				Color *hexColor
+				Addr  netip.Addr
+			}{
+				Color: &hexColor{r: 10, g: 11, b: 12},
+				Addr:  netip.MustParseAddr("10.1.2.3"),
+			},
+		},
+		{
+			name:     "format verbs",
+			template: "backup-{{Seq:%06d}}-{{Size:%.1f}}.tar",
+			result:   "backup-000123-10.5.tar",
+			out: &struct {
+				Seq  uint
+				Size float32
+			}{},
+			want: &struct {
+				Seq  uint
+				Size float32
+			}{
+				Seq:  123,
+				Size: 10.5,
+			},
+		},
 	}
 
 	for _, tt := range tests {
@@ -616,7 +1541,11 @@ func TestParse(t *testing.T) {
 			if err != nil {
 				t.Errorf("New.Parse() error = %v", err)
 			}
-			if diff := cmp.Diff(tt.out, tt.want); diff != "" {
+			opts := cmp.Options{
+				cmp.AllowUnexported(hexColor{}),
+				cmp.Comparer(func(a, b netip.Addr) bool { return a == b }),
+			}
+			if diff := cmp.Diff(tt.out, tt.want, opts); diff != "" {
 				t.Errorf("Parse() mismatch (-got +want)\n%s", diff)
 			}
 		})
@@ -0,0 +1,121 @@
+package twist
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// TypedTwist - a reversible template bound to the struct type T. Every field in the template
+// is checked against T when the template is created.
+type TypedTwist[T any] struct {
+	twist Twist
+}
+
+// NewTyped creates a 'twist' for executing and parsing values of type T, which must be a
+// struct or a pointer to a struct. It errors if the template is invalid, or if any of the
+// template's fields are missing from T or have a type that can't be parsed. See New for the
+// template syntax.
+func NewTyped[T any](s string, opts ...twistOption) (TypedTwist[T], error) {
+	t, err := New(s, opts...)
+	if err != nil {
+		return TypedTwist[T]{}, err
+	}
+	if err := t.checkType(reflect.TypeFor[T]()); err != nil {
+		return TypedTwist[T]{}, err
+	}
+	return TypedTwist[T]{twist: t}, nil
+}
+
+// MustNewTyped is a convenience function that wraps `NewTyped` and panics if the template is
+// invalid.
+func MustNewTyped[T any](s string, opts ...twistOption) TypedTwist[T] {
+	result, err := NewTyped[T](s, opts...)
+	if err != nil {
+		panic(err)
+	}
+	return result
+}
+
+// Twist returns the untyped template.
+func (t TypedTwist[T]) Twist() Twist {
+	return t.twist
+}
+
+// Execute executes the template with the given data and returns the generated string.
+func (t TypedTwist[T]) Execute(data T, opts ...executeOption) (string, error) {
+	return t.twist.Execute(data, opts...)
+}
+
+// Parse takes a string generated by executing the template and returns the original data,
+// see ParseAs.
+func (t TypedTwist[T]) Parse(s string) (T, error) {
+	return ParseAs[T](t.twist, s)
+}
+
+// ParseAll takes a string generated by executing the template and returns every possible
+// data set that could have generated it, see ParseAllAs.
+func (t TypedTwist[T]) ParseAll(s string) ([]T, error) {
+	return ParseAllAs[T](t.twist, s)
+}
+
+// Check that every field in the template exists in the struct type and has a type that can
+// be parsed
+func (t Twist) checkType(typ reflect.Type) error {
+	root := typ
+	for root.Kind() == reflect.Ptr {
+		root = root.Elem()
+	}
+	if root.Kind() != reflect.Struct {
+		return fmt.Errorf("type '%s' is not a struct: %w", typ, ErrInvalidConfig)
+	}
+
+	for _, f := range t.fieldParts {
+		fieldType, ok := lookupPathType(root, f.String())
+		if !ok {
+			return &FieldError{Field: f.String(), Reason: fmt.Sprintf("is missing from '%s'", typ), err: ErrInvalidTemplate}
+		}
+		if !t.supportsType(f, fieldType, true) {
+			return &FieldError{
+				Field:  f.String(),
+				Kind:   fieldType.String(),
+				Reason: fmt.Sprintf("has unsupported type '%s'", fieldType),
+				err:    ErrInvalidTemplate,
+			}
+		}
+	}
+	return nil
+}
+
+// Check if text parsed for a field can be decoded into the given type. Slices are supported
+// if the field is a list and their elements are.
+func (t Twist) supportsType(f field, typ reflect.Type, allowSlice bool) bool {
+	for {
+		if f.codec != nil && f.codec.typ.AssignableTo(typ) || findCodec(t.codecs, typ) != nil {
+			return true
+		}
+		if typ.Kind() != reflect.Ptr {
+			break
+		}
+		typ = typ.Elem()
+	}
+	if f.codec != nil && typ.Kind() != reflect.Slice {
+		return false
+	}
+	if isTextUnmarshaler(typ) {
+		return true
+	}
+
+	switch typ.Kind() {
+	case reflect.String, reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64:
+		return true
+	case reflect.Interface:
+		return typ.NumMethod() == 0
+	case reflect.Slice:
+		return allowSlice && f.list && t.supportsType(f, typ.Elem(), false)
+	default:
+		return false
+	}
+}
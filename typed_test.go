@@ -0,0 +1,204 @@
+package twist
+
+import (
+	"errors"
+	"net/netip"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestTypedTwist(t *testing.T) {
+	type Owner struct {
+		Team string
+	}
+	type Backup struct {
+		Host    netip.Addr
+		Owner   *Owner
+		When    time.Time
+		Parts   []uint16
+		Version semver
+		Labels  map[string]string
+		Region  string `twist:"region"`
+	}
+
+	tmpl, err := NewTyped[Backup](
+		"{{Owner.Team}}/{{region}}/{{Host}}/{{Labels.Env}}/{{When:time \"2006-01-02\"}}-{{Version}}-{{Parts...:uint}}",
+		WithLowercaseFields(),
+		WithCodec(encodeSemver, decodeSemver),
+	)
+	if err != nil {
+		t.Errorf("NewTyped() error = %v", err)
+		return
+	}
+
+	data := Backup{
+		Host:    netip.MustParseAddr("10.0.0.1"),
+		Owner:   &Owner{Team: "core"},
+		When:    time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC),
+		Parts:   []uint16{1, 2},
+		Version: semver{1, 0, 0},
+		Labels:  map[string]string{"Env": "prod"},
+		Region:  "eu",
+	}
+	got, err := tmpl.Execute(data)
+	if err != nil {
+		t.Errorf("Execute() error = %v", err)
+		return
+	}
+	if want := "core/eu/10.0.0.1/prod/2024-03-01-v1.0.0-1,2"; got != want {
+		t.Errorf("Execute() = %v, want %v", got, want)
+		return
+	}
+
+	out, err := tmpl.Parse(got)
+	if err != nil {
+		t.Errorf("Parse() error = %v", err)
+		return
+	}
+	opts := cmp.Comparer(func(a, b netip.Addr) bool { return a == b })
+	if diff := cmp.Diff(out, data, opts); diff != "" {
+		t.Errorf("Parse() mismatch (-got +want)\n%s", diff)
+	}
+
+	all, err := tmpl.ParseAll(got)
+	if err != nil {
+		t.Errorf("ParseAll() error = %v", err)
+		return
+	}
+	if diff := cmp.Diff(all, []Backup{data}, opts); diff != "" {
+		t.Errorf("ParseAll() mismatch (-got +want)\n%s", diff)
+	}
+	if tmpl.Twist().original != tmpl.twist.original {
+		t.Errorf("Twist() does not return the template")
+	}
+}
+
+func TestNewTypedError(t *testing.T) {
+	type Job struct {
+		Name    string
+		Owner   *struct{ Team string }
+		Labels  map[string]string
+		Done    chan bool
+		Errs    []error
+		Ids     []int
+		Nested  [][]string
+		Meta    any
+		Ignored string `twist:"-"`
+	}
+
+	type testCase struct {
+		name      string
+		newTyped  func() error
+		errorType error
+		errorMsg  string
+	}
+
+	check := func(template string, opts ...twistOption) func() error {
+		return func() error {
+			_, err := NewTyped[*Job](template, opts...)
+			return err
+		}
+	}
+	tests := []testCase{
+		{
+			name:      "invalid template",
+			newTyped:  check("{{Name"),
+			errorType: ErrInvalidTemplate,
+			errorMsg:  "unmatched delimiters",
+		},
+		{
+			name:      "typo",
+			newTyped:  check("{{Nmae}}"),
+			errorType: ErrInvalidTemplate,
+			errorMsg:  "field 'Nmae' is missing from '*twist.Job'",
+		},
+		{
+			name:      "missing nested field",
+			newTyped:  check("{{Owner.Name}}"),
+			errorType: ErrInvalidTemplate,
+			errorMsg:  "field 'Owner.Name' is missing",
+		},
+		{
+			name:      "path through a value",
+			newTyped:  check("{{Name.First}}"),
+			errorType: ErrInvalidTemplate,
+			errorMsg:  "field 'Name.First' is missing",
+		},
+		{
+			name:      "path through a map value",
+			newTyped:  check("{{Labels.Env.Name}}"),
+			errorType: ErrInvalidTemplate,
+			errorMsg:  "field 'Labels.Env.Name' is missing",
+		},
+		{
+			name:      "ignored field",
+			newTyped:  check("{{Ignored}}"),
+			errorType: ErrInvalidTemplate,
+			errorMsg:  "field 'Ignored' is missing",
+		},
+		{
+			name:      "unsupported type",
+			newTyped:  check("{{Done}}"),
+			errorType: ErrInvalidTemplate,
+			errorMsg:  "field 'Done' has unsupported type 'chan bool'",
+		},
+		{
+			name:      "unsupported element type",
+			newTyped:  check("{{Errs...}}"),
+			errorType: ErrInvalidTemplate,
+			errorMsg:  "field 'Errs' has unsupported type '[]error'",
+		},
+		{
+			name:      "slice without list",
+			newTyped:  check("{{Ids}}"),
+			errorType: ErrInvalidTemplate,
+			errorMsg:  "field 'Ids' has unsupported type '[]int'",
+		},
+		{
+			name:      "nested slice",
+			newTyped:  check("{{Nested...}}"),
+			errorType: ErrInvalidTemplate,
+			errorMsg:  "field 'Nested' has unsupported type '[][]string'",
+		},
+		{
+			name:      "codec for another type",
+			newTyped:  check("{{Name|shard}}", WithNamedCodec("shard", encodeShard, decodeShard)),
+			errorType: ErrInvalidTemplate,
+			errorMsg:  "field 'Name' has unsupported type 'string'",
+		},
+		{
+			name: "not a struct",
+			newTyped: func() error {
+				_, err := NewTyped[map[string]string]("{{Name}}")
+				return err
+			},
+			errorType: ErrInvalidConfig,
+			errorMsg:  "type 'map[string]string' is not a struct",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.newTyped()
+			if err == nil {
+				t.Errorf("NewTyped() error is nil")
+				return
+			}
+			if !errors.Is(err, tt.errorType) {
+				t.Errorf("NewTyped() error type = '%v', want type '%v'", err, tt.errorType)
+				return
+			}
+			if !strings.Contains(err.Error(), tt.errorMsg) {
+				t.Errorf("NewTyped() error = '%v', want to contain '%v'", err, tt.errorMsg)
+			}
+		})
+	}
+
+	// Fields inside interfaces can't be checked until they are used
+	if _, err := NewTyped[Job]("{{Meta.Anything}}-{{Name}}"); err != nil {
+		t.Errorf("NewTyped() error = %v", err)
+	}
+}
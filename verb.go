@@ -0,0 +1,123 @@
+package twist
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// A printf style verb, e.g. %06d, used to format a field when executing a template and
+// to recognise the text it produces when parsing.
+type printfVerb struct {
+	spec   string
+	char   byte
+	padded bool
+}
+
+var verbPattern = regexp.MustCompile(`^%[-+0]*([0-9]*)(\.[0-9]+)?([dxXobfFeEgGst])$`)
+
+// Construct a new printfVerb, returns false if the verb is not supported
+func newPrintfVerb(s string) (printfVerb, bool) {
+	match := verbPattern.FindStringSubmatch(s)
+	if match == nil {
+		return printfVerb{}, false
+	}
+	return printfVerb{spec: s, char: match[3][0], padded: match[1] != ""}, true
+}
+
+// Return the number base used by an integer verb, or 0 if the verb is not for integers
+func (v printfVerb) base() int {
+	switch v.char {
+	case 'd':
+		return 10
+	case 'x', 'X':
+		return 16
+	case 'o':
+		return 8
+	case 'b':
+		return 2
+	}
+	return 0
+}
+
+// Format a value using the verb. Strings are accepted for any verb so long as they
+// hold a value of the right type, e.g. "42" for %06d.
+func (v printfVerb) format(value any) (string, error) {
+	val := reflect.ValueOf(value)
+	for val.Kind() == reflect.Ptr && !val.IsNil() {
+		val = val.Elem()
+	}
+	if val.Kind() == reflect.String && v.char != 's' {
+		parsed, err := v.parse(val.String(), 10)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf(v.spec, parsed), nil
+	}
+
+	var ok bool
+	switch v.char {
+	case 'd', 'x', 'X', 'o', 'b':
+		ok = val.CanInt() || val.CanUint()
+	case 'f', 'F', 'e', 'E', 'g', 'G':
+		ok = val.CanFloat()
+	case 't':
+		ok = val.Kind() == reflect.Bool
+	case 's':
+		s, err := toString(value)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf(v.spec, s), nil
+	}
+	if !ok {
+		return "", errors.New("value cannot be formatted with verb")
+	}
+	return fmt.Sprintf(v.spec, val.Interface()), nil
+}
+
+// Parse text into the type of value the verb formats. Integers are parsed using the
+// given base.
+func (v printfVerb) parse(s string, base int) (any, error) {
+	switch v.char {
+	case 'd', 'x', 'X', 'o', 'b':
+		s = strings.TrimSpace(s)
+		if i, err := strconv.ParseInt(s, base, 64); err == nil {
+			return i, nil
+		}
+		return strconv.ParseUint(s, base, 64)
+	case 'f', 'F', 'e', 'E', 'g', 'G':
+		return strconv.ParseFloat(strings.TrimSpace(s), 64)
+	case 't':
+		return strconv.ParseBool(strings.TrimSpace(s))
+	}
+	if v.padded {
+		s = strings.TrimSpace(s)
+	}
+	return s, nil
+}
+
+// Check the text could have been produced by the verb
+func (v printfVerb) accepts(s string) bool {
+	value, err := v.parse(s, v.base())
+	return err == nil && fmt.Sprintf(v.spec, value) == s
+}
+
+// Strip any padding from the text and, for integers, convert it to base 10 so that it
+// can be decoded.
+func (v printfVerb) normalise(s string) string {
+	value, err := v.parse(s, v.base())
+	if err != nil {
+		return s
+	}
+	if v.base() != 0 {
+		return fmt.Sprintf("%d", value)
+	}
+	if str, ok := value.(string); ok {
+		return str
+	}
+	return strings.TrimSpace(s)
+}